@@ -0,0 +1,91 @@
+package frango
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Access log formats for WithAccessLog
+const (
+	// AccessLogText writes one space-separated line per request
+	AccessLogText = "text"
+	// AccessLogJSON writes one JSON object per line, for log pipelines
+	AccessLogJSON = "json"
+)
+
+// accessLog writes one entry per PHP request
+type accessLog struct {
+	out    io.Writer
+	format string
+	// mutex keeps concurrent entries from interleaving
+	mutex sync.Mutex
+}
+
+// accessLogEntry is a single access log record
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	Script     string    `json:"script"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+// WithAccessLog writes one line per PHP request to out with its method, path, status,
+// duration and the script that ran, separate from the internal logger. format is
+// AccessLogText (the default when empty) or AccessLogJSON.
+func WithAccessLog(out io.Writer, format string) Option {
+	return func(m *Middleware) {
+		if format == "" {
+			format = AccessLogText
+		}
+		m.accessLog = &accessLog{out: out, format: format}
+	}
+}
+
+// logAccess wraps w to capture the status and returns the writer to use and a
+// function writing the access log entry
+func (m *Middleware) logAccess(w http.ResponseWriter, r *http.Request, scriptPath string) (http.ResponseWriter, func()) {
+	if m.accessLog == nil {
+		return w, func() {}
+	}
+
+	started := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w}
+	return recorder, func() {
+		m.accessLog.write(accessLogEntry{
+			Time:       started,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Status:     recorder.statusCode(),
+			DurationMs: float64(time.Since(started).Microseconds()) / 1000,
+			Script:     scriptPath,
+			RequestID:  requestID(r),
+		})
+	}
+}
+
+// write formats and writes an entry
+func (l *accessLog) write(entry accessLogEntry) {
+	var line []byte
+	if l.format == AccessLogJSON {
+		line, _ = json.Marshal(entry)
+		line = append(line, '\n')
+	} else {
+		line = []byte(fmt.Sprintf("%s %s %q %d %.3fms %s",
+			entry.Time.Format(time.RFC3339), entry.Method, entry.Path, entry.Status, entry.DurationMs, entry.Script))
+		if entry.RequestID != "" {
+			line = append(line, " request_id="+entry.RequestID...)
+		}
+		line = append(line, '\n')
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.out.Write(line)
+}
@@ -0,0 +1,86 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// annotatingWriter wraps PHP HTML output with comments naming the script that produced it.
+// PHP offers no hook around include/require, so partials are only named when included
+// through the frango_include() helper.
+type annotatingWriter struct {
+	http.ResponseWriter
+	script string
+	// decided is set once the headers show whether the response is annotated
+	decided bool
+	html    bool
+	// opened is set once the opening annotation is written
+	opened bool
+	// noBody is set for requests whose response must not carry one (HEAD)
+	noBody bool
+}
+
+// newAnnotatingWriter creates a writer that annotates output produced by script
+func newAnnotatingWriter(w http.ResponseWriter, r *http.Request, script string) *annotatingWriter {
+	return &annotatingWriter{ResponseWriter: w, script: script, noBody: r.Method == http.MethodHead}
+}
+
+// WriteHeader decides whether the response is annotated before its headers go out,
+// dropping the Content-Length PHP computed for the unannotated body
+func (a *annotatingWriter) WriteHeader(status int) {
+	if !a.decided && status >= http.StatusOK {
+		a.decided = true
+
+		// PHP defaults to text/html when the script doesn't set a Content-Type
+		contentType := a.Header().Get("Content-Type")
+		a.html = !a.noBody && bodyAllowed(status) &&
+			(contentType == "" || strings.HasPrefix(contentType, "text/html"))
+		if a.html {
+			a.Header().Del("Content-Length")
+		}
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+// Write emits the opening annotation before the first chunk of HTML output
+func (a *annotatingWriter) Write(p []byte) (int, error) {
+	if !a.decided {
+		a.WriteHeader(http.StatusOK)
+	}
+	if a.html && !a.opened && len(p) > 0 {
+		a.opened = true
+		fmt.Fprintf(a.ResponseWriter, "<!-- frango: begin %s -->\n", a.script)
+	}
+	return a.ResponseWriter.Write(p)
+}
+
+// Flush passes PHP flush() calls through to the underlying writer
+func (a *annotatingWriter) Flush() {
+	if flusher, ok := a.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (a *annotatingWriter) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// finish emits the closing annotation if the opening one was written
+func (a *annotatingWriter) finish() {
+	if a.opened {
+		fmt.Fprintf(a.ResponseWriter, "\n<!-- frango: end %s -->\n", a.script)
+	}
+}
+
+// WithSourceAnnotations wraps HTML output in development mode with comments naming
+// the script that produced it, e.g. <!-- frango: begin pages/about.php -->.
+// Partials are named too when included with frango_include('partial.php') rather than
+// include, and responses without a body are left alone. It has no effect in
+// production mode.
+func WithSourceAnnotations(enabled bool) Option {
+	return func(m *Middleware) {
+		m.sourceAnnotations = enabled
+	}
+}
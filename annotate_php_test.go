@@ -0,0 +1,28 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFrangoIncludeAnnotatesPartials(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"page.php":            `<?php echo frango_include('partials/header.php', ['title' => 'Home']); ?><p>body</p>`,
+		"partials/header.php": `<h1><?= $title ?></h1><?php return '';`,
+	}, quietLogger(), WithDevelopmentMode(true), WithSourceAnnotations(true))
+	defer cleanup()
+
+	body := serve(m, http.MethodGet, "/page.php").Body.String()
+	for _, want := range []string{
+		"<!-- frango: begin page.php -->",
+		"<!-- frango: begin partials/header.php -->\n<h1>Home</h1>\n<!-- frango: end partials/header.php -->",
+		"<!-- frango: end page.php -->",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body misses %q:\n%s", want, body)
+		}
+	}
+}
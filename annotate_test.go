@@ -0,0 +1,70 @@
+package frango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnnotatingWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		status      int
+		body        string
+		want        string
+	}{
+		{"html", http.MethodGet, "", 0, "<p>hi</p>", "<!-- frango: begin page.php -->\n<p>hi</p>\n<!-- frango: end page.php -->\n"},
+		{"json", http.MethodGet, "application/json", 0, "{}", "{}"},
+		{"not modified", http.MethodGet, "", http.StatusNotModified, "", ""},
+		{"no content", http.MethodGet, "", http.StatusNoContent, "", ""},
+		{"empty write", http.MethodGet, "", http.StatusOK, "", ""},
+		{"head", http.MethodHead, "", http.StatusOK, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			a := newAnnotatingWriter(recorder, httptest.NewRequest(tt.method, "/page", nil), "page.php")
+			if tt.contentType != "" {
+				a.Header().Set("Content-Type", tt.contentType)
+			}
+			if tt.status != 0 {
+				a.WriteHeader(tt.status)
+			}
+			a.Write([]byte(tt.body))
+			a.finish()
+
+			if got := recorder.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotatingWriterDropsContentLength(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	a := newAnnotatingWriter(recorder, httptest.NewRequest(http.MethodGet, "/page", nil), "page.php")
+
+	// PHP sets the length of the body it produces, then sends its headers
+	a.Header().Set("Content-Length", "9")
+	a.WriteHeader(http.StatusOK)
+	a.Write([]byte("<p>hi</p>"))
+	a.finish()
+
+	if length := recorder.Result().Header.Get("Content-Length"); length != "" {
+		t.Errorf("Content-Length %s sent for an annotated body of %d bytes", length, recorder.Body.Len())
+	}
+
+	// Responses that aren't annotated keep it
+	recorder = httptest.NewRecorder()
+	a = newAnnotatingWriter(recorder, httptest.NewRequest(http.MethodGet, "/data", nil), "data.php")
+	a.Header().Set("Content-Type", "application/json")
+	a.Header().Set("Content-Length", "2")
+	a.WriteHeader(http.StatusOK)
+	a.Write([]byte("{}"))
+	if length := recorder.Result().Header.Get("Content-Length"); length != "2" {
+		t.Errorf("Content-Length = %q for JSON, want 2", length)
+	}
+}
@@ -0,0 +1,54 @@
+package frango
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithBasePath tells frango the URL prefix it's mounted under (e.g. "/php"), so scripts
+// see the full original URL: REQUEST_URI, SCRIPT_NAME and PHP_SELF include the prefix
+// and $_SERVER['FRANGO_BASE_PATH'] (also frango_BASE_PATH, like the other frango_
+// variables) holds it for building links. Redirecting rewrite rules keep the prefix in
+// their Location. Scripts are still resolved relative to the source directory, whether
+// or not the prefix was already removed with http.StripPrefix.
+func WithBasePath(basePath string) Option {
+	return func(m *Middleware) {
+		basePath = strings.TrimSuffix(basePath, "/")
+		if basePath != "" && !strings.HasPrefix(basePath, "/") {
+			basePath = "/" + basePath
+		}
+		m.basePath = basePath
+	}
+}
+
+// stripBasePath removes the base path from requests that still carry it
+func (m *Middleware) stripBasePath(r *http.Request) *http.Request {
+	if m.basePath == "" {
+		return r
+	}
+
+	rest, found := strings.CutPrefix(r.URL.Path, m.basePath)
+	if !found || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return r
+	}
+	if rest == "" {
+		rest = "/"
+	}
+
+	stripped := r.Clone(r.Context())
+	stripped.URL.Path = rest
+	stripped.URL.RawPath = ""
+	return stripped
+}
+
+// addBasePathEnv prefixes the URL variables PHP sees with the base path
+func (m *Middleware) addBasePathEnv(env map[string]string) {
+	if m.basePath == "" {
+		return
+	}
+	env["FRANGO_BASE_PATH"] = m.basePath
+	env["frango_BASE_PATH"] = m.basePath
+	for _, key := range []string{"REQUEST_URI", "SCRIPT_NAME", "PHP_SELF"} {
+		env[key] = m.basePath + env[key]
+	}
+}
@@ -0,0 +1,106 @@
+package frango
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+)
+
+// WidgetSpec describes one PHP partial rendered by RenderBatch
+type WidgetSpec struct {
+	// ScriptPath is the PHP partial to render, relative to the source directory
+	ScriptPath string
+	// Data optionally supplies render data for the partial
+	Data RenderData
+}
+
+// RenderBatch returns a handler that renders several PHP partials for one request and
+// responds with a JSON object mapping each widget name to its rendered output.
+// Widgets render in parallel, at most runtime.NumCPU() at a time. A widget that
+// fails to render maps to an empty string, as does one whose render function panics
+// when WithRecover is enabled; without it the panic reaches the request's goroutine.
+func (m *Middleware) RenderBatch(widgets map[string]WidgetSpec) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results := make(map[string]string, len(widgets))
+		var resultsMutex sync.Mutex
+		var wg sync.WaitGroup
+
+		// A panic escaping a worker would crash the process, so it's carried back to
+		// the request's goroutine where net/http handles it like any handler panic
+		var workerPanic interface{}
+		var panicOnce sync.Once
+
+		// Bound how many PHP partials run at once
+		semaphore := make(chan struct{}, runtime.NumCPU())
+
+		for name, spec := range widgets {
+			wg.Add(1)
+			go func(name string, spec WidgetSpec) {
+				defer wg.Done()
+				defer func() {
+					if p := recover(); p != nil {
+						panicOnce.Do(func() { workerPanic = p })
+					}
+				}()
+
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				output := m.renderWidget(r, name, spec)
+
+				resultsMutex.Lock()
+				results[name] = output
+				resultsMutex.Unlock()
+			}(name, spec)
+		}
+		wg.Wait()
+		if workerPanic != nil {
+			panic(workerPanic)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			m.logger.Printf("Error encoding batch render response: %v", err)
+		}
+	})
+}
+
+// renderWidget renders a single widget and returns its output
+func (m *Middleware) renderWidget(r *http.Request, name string, spec WidgetSpec) string {
+	// Each widget gets its own body-less GET request
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+	req.Body = http.NoBody
+	req.ContentLength = 0
+
+	buffered := newBufferedResponse()
+
+	data, err := m.widgetData(spec, buffered, req)
+	if err != nil {
+		m.logger.Printf("Error rendering widget %s: %v", name, err)
+		return ""
+	}
+
+	if err := m.executeScript(req, spec.ScriptPath, data, buffered); err != nil {
+		m.logger.Printf("Error rendering widget %s: %v", name, err)
+		return ""
+	}
+
+	if status := buffered.statusCode(); status != http.StatusOK {
+		m.logger.Printf("Widget %s (%s) responded with status %d", name, spec.ScriptPath, status)
+		return ""
+	}
+
+	return buffered.body.String()
+}
+
+// widgetData calls the widget's render function; with WithRecover a panic in it is
+// returned as an error so only that widget fails
+func (m *Middleware) widgetData(spec WidgetSpec, w http.ResponseWriter, r *http.Request) (data map[string]interface{}, err error) {
+	defer m.recoverPanicError(spec.ScriptPath, &err)
+	if spec.Data != nil {
+		data = spec.Data(w, r)
+	}
+	return data, nil
+}
@@ -0,0 +1,53 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRenderBatchRendersWidgets(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"widgets/greeting.php": `<?php echo "Hello, " . frango_var('name', 'nobody') . "!";`,
+		"widgets/clock.php":    `<?php echo "<time>" . $_SERVER['REQUEST_METHOD'] . "</time>";`,
+		"widgets/broken.php":   `<?php http_response_code(500); echo "failed";`,
+	}, quietLogger())
+	defer cleanup()
+
+	batch := m.RenderBatch(map[string]WidgetSpec{
+		"greeting": {
+			ScriptPath: "widgets/greeting.php",
+			Data: func(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+				return map[string]interface{}{"name": "Ada"}
+			},
+		},
+		"clock":  {ScriptPath: "widgets/clock.php"},
+		"broken": {ScriptPath: "widgets/broken.php"},
+	})
+
+	// Widgets render as GETs whatever the batch request's method
+	recorder := serve(batch, http.MethodPost, "/widgets")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("batch = %d %s, want a 200 JSON response", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	var results map[string]string
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatalf("response isn't JSON: %v\n%s", err, recorder.Body.String())
+	}
+	want := map[string]string{
+		"greeting": "Hello, Ada!",
+		"clock":    "<time>GET</time>",
+		"broken":   "",
+	}
+	for name, output := range want {
+		if got, found := results[name]; !found || got != output {
+			t.Errorf("widget %s = %q (present %t), want %q", name, got, found, output)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("results = %v, want exactly %d widgets", results, len(want))
+	}
+}
@@ -0,0 +1,43 @@
+package frango
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// panickingWidget is a widget whose render function panics
+var panickingWidget = WidgetSpec{
+	ScriptPath: "widget.php",
+	Data: func(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+		panic("render data unavailable")
+	},
+}
+
+func TestRenderBatchRecoversWidgetPanics(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{"widget.php": "<?php"}, quietLogger(), WithRecover(true))
+	defer cleanup()
+
+	recorder := serve(m.RenderBatch(map[string]WidgetSpec{"broken": panickingWidget}), http.MethodGet, "/widgets")
+
+	var results map[string]string
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatalf("response isn't JSON (status %d): %v", recorder.Code, err)
+	}
+	if output, found := results["broken"]; !found || output != "" {
+		t.Errorf("results = %v, want the panicking widget as an empty entry", results)
+	}
+}
+
+func TestRenderBatchRaisesPanicsInRequestGoroutine(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{"widget.php": "<?php"}, quietLogger())
+	defer cleanup()
+
+	defer func() {
+		if p := recover(); p != "render data unavailable" {
+			t.Errorf("recovered %v, want the widget's panic", p)
+		}
+	}()
+	serve(m.RenderBatch(map[string]WidgetSpec{"broken": panickingWidget}), http.MethodGet, "/widgets")
+	t.Error("the widget's panic didn't reach the handler")
+}
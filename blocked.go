@@ -0,0 +1,64 @@
+package frango
+
+import (
+	"path"
+	"strings"
+)
+
+// defaultBlockedPaths are files under the source directory that are never served
+var defaultBlockedPaths = []string{
+	".env",
+	".env.*",
+	"*.lock",
+	".git/",
+	".htaccess",
+	".htpasswd",
+	"composer.json",
+}
+
+// WithBlockedPaths answers requests for source directory files matching any of the
+// glob patterns with 404 Not Found, whether they'd be run as PHP or served as static
+// files. Patterns without a slash match any path segment ("*.lock", ".env"); a trailing
+// slash matches a directory and everything in it (".git/", "vendor/"); patterns with a
+// slash match from the source directory root ("config/*.ini"). Matching ignores case.
+// They're added to the defaults: .env, .env.*, *.lock, .git/, .htaccess, .htpasswd and
+// composer.json.
+func WithBlockedPaths(patterns ...string) Option {
+	return func(m *Middleware) {
+		m.blockedPaths = append(m.blockedPaths, patterns...)
+	}
+}
+
+// isBlockedPath reports whether a URL path maps to a blocked file
+func (m *Middleware) isBlockedPath(urlPath string) bool {
+	relPath := strings.TrimPrefix(path.Clean("/"+strings.ToLower(urlPath)), "/")
+	if relPath == "" {
+		return false
+	}
+	segments := strings.Split(relPath, "/")
+
+	for _, pattern := range m.blockedPaths {
+		pattern = strings.ToLower(pattern)
+		dirOnly := strings.HasSuffix(pattern, "/")
+		pattern = strings.Trim(pattern, "/")
+
+		if strings.Contains(pattern, "/") {
+			// Rooted pattern: match the path or, for directories, any of its parents
+			depth := strings.Count(pattern, "/") + 1
+			if depth > len(segments) || (!dirOnly && depth != len(segments)) {
+				continue
+			}
+			if matched, _ := path.Match(pattern, strings.Join(segments[:depth], "/")); matched {
+				return true
+			}
+			continue
+		}
+
+		for _, segment := range segments {
+			if matched, _ := path.Match(pattern, segment); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestIsBlockedPath(t *testing.T) {
+	m := &Middleware{
+		blockedPaths: append([]string(nil), defaultBlockedPaths...),
+		logger:       log.New(io.Discard, "", 0),
+	}
+	WithBlockedPaths("vendor/", "config/*.ini")(m)
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/.env", true},
+		{"/.ENV", true},
+		{"/.env.local", true},
+		{"/app/.env", true},
+		{"/composer.json", true},
+		{"/composer.lock", true},
+		{"/.git/config", true},
+		{"/.git/", true},
+		{"/.htaccess", true},
+		{"/vendor/autoload.php", true},
+		{"/vendor/", true},
+		{"/config/app.ini", true},
+		{"/app/config/app.ini", false},
+		{"/config/app.php", false},
+		{"/config/nested/app.ini", false},
+		{"/", false},
+		{"/index.php", false},
+		{"/environment.php", false},
+		{"/vendors.php", false},
+		{"/public/package.json", false},
+	}
+	for _, tt := range tests {
+		if got := m.isBlockedPath(tt.path); got != tt.want {
+			t.Errorf("isBlockedPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
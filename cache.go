@@ -0,0 +1,334 @@
+package frango
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheTagHeader is the response header PHP scripts set to tag a cached response.
+// Multiple tags can be given as a comma-separated list, e.g. header('X-Cache-Tag: user-42, users');
+const CacheTagHeader = "X-Cache-Tag"
+
+// defaultResponseCacheEntries bounds the response cache unless WithResponseCacheMaxEntries says otherwise
+const defaultResponseCacheEntries = 1000
+
+// perRequestHeaders describe a single response and are never replayed from the cache
+var perRequestHeaders = []string{"Server-Timing", "Set-Cookie"}
+
+// cachedResponse is a stored PHP response along with its tags
+type cachedResponse struct {
+	response *bufferedResponse
+	tags     []string
+	expires  time.Time
+	// base is the key of the URL the response was stored for, before Vary
+	base string
+}
+
+// varyFields are the request headers the responses stored for a URL vary on
+type varyFields struct {
+	fields []string
+	// entries counts the stored responses for the URL, the fields go with the last one
+	entries int
+}
+
+// responseCache stores successful GET responses keyed by request URI
+type responseCache struct {
+	// ttl is how long an entry stays valid
+	ttl time.Duration
+	// maxEntries bounds the number of stored responses
+	maxEntries int
+	// entries maps cache keys to stored responses
+	entries map[string]*cachedResponse
+	// tags maps each cache tag to the keys carrying it
+	tags map[string]map[string]struct{}
+	// vary maps a URL's base key to the request headers its responses vary on
+	vary map[string]*varyFields
+	// mutex controls concurrent access to entries and tags
+	mutex sync.RWMutex
+}
+
+// newResponseCache creates a response cache with the given TTL
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:        ttl,
+		maxEntries: defaultResponseCacheEntries,
+		entries:    make(map[string]*cachedResponse),
+		tags:       make(map[string]map[string]struct{}),
+		vary:       make(map[string]*varyFields),
+	}
+}
+
+// get returns a non-expired cached response for a key
+func (c *responseCache) get(key string) (*bufferedResponse, bool) {
+	c.mutex.RLock()
+	entry, exists := c.entries[key]
+	c.mutex.RUnlock()
+
+	if !exists || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.response, true
+}
+
+// lookup returns the stored response for a URL's base key matching the request
+// headers the URL's responses vary on
+func (c *responseCache) lookup(base string, r *http.Request) (*bufferedResponse, bool) {
+	c.mutex.RLock()
+	var fields []string
+	if vary, exists := c.vary[base]; exists {
+		fields = vary.fields
+	}
+	c.mutex.RUnlock()
+
+	return c.get(variantKey(base, fields, r))
+}
+
+// set stores a response for a key that varies on nothing
+func (c *responseCache) set(key string, response *bufferedResponse) {
+	c.setVariant(key, nil, nil, response)
+}
+
+// setVariant stores a response for a URL's base key and the request's values of the
+// headers it varies on, indexing it by any tags declared in CacheTagHeader
+func (c *responseCache) setVariant(base string, fields []string, r *http.Request, response *bufferedResponse) {
+	key := variantKey(base, fields, r)
+	tags := parseCacheTags(response.Header().Values(CacheTagHeader))
+
+	// The tag header is internal bookkeeping, don't leak it to clients
+	response.Header().Del(CacheTagHeader)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeLocked(key)
+	if c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.evictLocked()
+	}
+	c.entries[key] = &cachedResponse{
+		response: response,
+		tags:     tags,
+		expires:  time.Now().Add(c.ttl),
+		base:     base,
+	}
+	if c.vary[base] == nil {
+		c.vary[base] = &varyFields{}
+	}
+	c.vary[base].fields = fields
+	c.vary[base].entries++
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+}
+
+// invalidateTag removes every entry carrying the tag and returns how many were purged
+func (c *responseCache) invalidateTag(tag string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// removeLocked prunes this same set as it goes, so count it first
+	keys := c.tags[tag]
+	count := len(keys)
+	for key := range keys {
+		c.removeLocked(key)
+	}
+	delete(c.tags, tag)
+	return count
+}
+
+// clear removes every entry
+func (c *responseCache) clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries = make(map[string]*cachedResponse)
+	c.tags = make(map[string]map[string]struct{})
+	c.vary = make(map[string]*varyFields)
+}
+
+// evictLocked makes room for an entry by dropping expired responses or, if none
+// have expired, the one closest to expiring; caller must hold the lock
+func (c *responseCache) evictLocked() {
+	now := time.Now()
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			c.removeLocked(key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+	if len(c.entries) >= c.maxEntries && oldestKey != "" {
+		c.removeLocked(oldestKey)
+	}
+}
+
+// removeLocked drops an entry and its tag index references; caller must hold the lock
+func (c *responseCache) removeLocked(key string) {
+	entry, exists := c.entries[key]
+	if !exists {
+		return
+	}
+	for _, tag := range entry.tags {
+		delete(c.tags[tag], key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	if vary := c.vary[entry.base]; vary != nil {
+		if vary.entries--; vary.entries <= 0 {
+			delete(c.vary, entry.base)
+		}
+	}
+	delete(c.entries, key)
+}
+
+// variantKey extends a URL's base key with the request's values of the headers its
+// responses vary on. Accept-Encoding is reduced to the encoding frango would pick.
+func variantKey(base string, fields []string, r *http.Request) string {
+	key := base
+	for _, field := range fields {
+		value := strings.Join(r.Header.Values(field), ",")
+		if field == "Accept-Encoding" {
+			value = acceptedEncoding(r.Header.Values(field))
+		}
+		key += "\x00" + field + "=" + value
+	}
+	return key
+}
+
+// responseVary returns the sorted request headers listed in the Vary headers, and
+// false for Vary: *, which can't be cached
+func responseVary(headers ...http.Header) ([]string, bool) {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, header := range headers {
+		for _, value := range header.Values("Vary") {
+			for _, field := range strings.Split(value, ",") {
+				field = http.CanonicalHeaderKey(strings.TrimSpace(field))
+				if field == "*" {
+					return nil, false
+				}
+				if field != "" && !seen[field] {
+					seen[field] = true
+					fields = append(fields, field)
+				}
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields, true
+}
+
+// parseCacheTags splits the raw header values into individual trimmed tags
+func parseCacheTags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// serveCached serves a GET request from the response cache, or runs serve and
+// caches its output when PHP responds with 200 OK. Requests carrying credentials
+// may get a personalized response, so they bypass the cache entirely. Responses are
+// stored per value of the request headers their Vary lists, including the Vary set
+// on w before PHP ran (e.g. Origin for CORS); Vary: * isn't cached.
+func (m *Middleware) serveCached(w http.ResponseWriter, r *http.Request, serve func(w http.ResponseWriter)) {
+	if hasCredentials(r) {
+		serve(w)
+		return
+	}
+
+	key := m.responseCacheKey(r)
+
+	if cached, found := m.responseCache.lookup(key, r); found {
+		m.logger.Printf("Serving %s from response cache", r.URL.RequestURI())
+		cached.writeTo(w)
+		return
+	}
+
+	buffered := newBufferedResponse()
+	serve(buffered)
+
+	// Responses setting cookies are per-client and must never be replayed to others
+	if buffered.statusCode() == http.StatusOK && len(buffered.Header().Values("Set-Cookie")) == 0 {
+		if fields, cacheable := responseVary(w.Header(), buffered.Header()); cacheable {
+			m.responseCache.setVariant(key, fields, r, m.cacheableCopy(buffered))
+		}
+	}
+	buffered.Header().Del(CacheTagHeader)
+	buffered.writeTo(w)
+}
+
+// cacheableCopy returns a copy of a response to store, without the headers that
+// only describe this one request
+func (m *Middleware) cacheableCopy(b *bufferedResponse) *bufferedResponse {
+	stored := newBufferedResponse()
+	stored.header = b.header.Clone()
+	stored.status = b.status
+	stored.body.Write(b.body.Bytes())
+
+	for _, name := range perRequestHeaders {
+		stored.header.Del(name)
+	}
+	if m.requestIDHeader != "" {
+		stored.header.Del(m.requestIDHeader)
+	}
+	return stored
+}
+
+// hasCredentials reports whether a request identifies its client, so its response
+// may be personalized
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Cookie") != "" || r.Header.Get("Authorization") != ""
+}
+
+// responseCacheKey returns the base response cache key for a request: the host and
+// URI, which the response's Vary extends (see variantKey)
+func (m *Middleware) responseCacheKey(r *http.Request) string {
+	key := r.Host + r.URL.RequestURI()
+
+	// Compressed and plain responses are different bodies for the same URI
+	if m.compression {
+		key += "\x00" + acceptedEncoding(r.Header.Values("Accept-Encoding"))
+	}
+	return key
+}
+
+// WithResponseCache enables caching of successful GET responses for the given TTL
+func WithResponseCache(ttl time.Duration) Option {
+	return func(m *Middleware) {
+		m.responseCache = newResponseCache(ttl)
+	}
+}
+
+// WithResponseCacheMaxEntries bounds the number of responses WithResponseCache keeps
+// (1000 by default). When full, expired entries are dropped first, then the oldest.
+func WithResponseCacheMaxEntries(maxEntries int) Option {
+	return func(m *Middleware) {
+		m.responseCacheMaxEntries = maxEntries
+	}
+}
+
+// InvalidateCacheTag purges all cached responses tagged with tag and returns the number removed
+func (m *Middleware) InvalidateCacheTag(tag string) int {
+	if m.responseCache == nil {
+		return 0
+	}
+	count := m.responseCache.invalidateTag(tag)
+	m.logger.Printf("Invalidated %d cached responses for tag %s", count, tag)
+	return count
+}
@@ -0,0 +1,263 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestCacheMiddleware returns a bare Middleware with a response cache, enough
+// to exercise serveCached without PHP
+func newTestCacheMiddleware() *Middleware {
+	return &Middleware{
+		logger:        log.New(io.Discard, "", 0),
+		responseCache: newResponseCache(time.Minute),
+	}
+}
+
+// cachedPage returns a buffered 200 response with body and tags
+func cachedPage(body string, tags string) *bufferedResponse {
+	response := newBufferedResponse()
+	if tags != "" {
+		response.Header().Set(CacheTagHeader, tags)
+	}
+	response.Write([]byte(body))
+	return response
+}
+
+func TestResponseCacheInvalidateTag(t *testing.T) {
+	cache := newResponseCache(time.Minute)
+	cache.set("/users/42", cachedPage("user 42", "user-42, users"))
+	cache.set("/users/7", cachedPage("user 7", "user-7, users"))
+	cache.set("/about", cachedPage("about", ""))
+
+	if cached, found := cache.get("/users/42"); !found {
+		t.Fatal("expected /users/42 to be cached")
+	} else if cached.Header().Get(CacheTagHeader) != "" {
+		t.Errorf("tag header was stored: %q", cached.Header().Get(CacheTagHeader))
+	}
+
+	if purged := cache.invalidateTag("user-42"); purged != 1 {
+		t.Errorf("invalidateTag(user-42) purged %d entries, want 1", purged)
+	}
+	if _, found := cache.get("/users/42"); found {
+		t.Error("/users/42 still cached after invalidating user-42")
+	}
+	if _, found := cache.get("/users/7"); !found {
+		t.Error("/users/7 was purged by an unrelated tag")
+	}
+
+	if purged := cache.invalidateTag("users"); purged != 1 {
+		t.Errorf("invalidateTag(users) purged %d entries, want 1", purged)
+	}
+	if _, found := cache.get("/users/7"); found {
+		t.Error("/users/7 still cached after invalidating users")
+	}
+	if _, found := cache.get("/about"); !found {
+		t.Error("untagged /about was purged")
+	}
+	if len(cache.tags) != 0 {
+		t.Errorf("tag index not emptied: %v", cache.tags)
+	}
+}
+
+func TestResponseCacheReplacingEntryDropsOldTags(t *testing.T) {
+	cache := newResponseCache(time.Minute)
+	cache.set("/page", cachedPage("v1", "old"))
+	cache.set("/page", cachedPage("v2", "new"))
+
+	if purged := cache.invalidateTag("old"); purged != 0 {
+		t.Errorf("invalidateTag(old) purged %d entries, want 0", purged)
+	}
+	if cached, found := cache.get("/page"); !found || cached.body.String() != "v2" {
+		t.Error("replaced entry was lost")
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	cache := newResponseCache(-time.Second)
+	cache.set("/page", cachedPage("stale", ""))
+
+	if _, found := cache.get("/page"); found {
+		t.Error("expired entry was served")
+	}
+}
+
+func TestResponseCacheMaxEntries(t *testing.T) {
+	cache := newResponseCache(time.Minute)
+	cache.maxEntries = 2
+	cache.set("/a", cachedPage("a", "t"))
+	time.Sleep(time.Millisecond)
+	cache.set("/b", cachedPage("b", "t"))
+	time.Sleep(time.Millisecond)
+	cache.set("/c", cachedPage("c", "t"))
+
+	if len(cache.entries) != 2 {
+		t.Fatalf("cache holds %d entries, want 2", len(cache.entries))
+	}
+	if _, found := cache.get("/a"); found {
+		t.Error("oldest entry /a was not evicted")
+	}
+	if len(cache.tags["t"]) != 2 {
+		t.Errorf("tag index holds %d keys, want 2", len(cache.tags["t"]))
+	}
+}
+
+func TestServeCachedSkipsCredentialedRequests(t *testing.T) {
+	m := newTestCacheMiddleware()
+	runs := 0
+	serve := func(w http.ResponseWriter) {
+		runs++
+		w.Write([]byte("hello"))
+	}
+
+	for _, header := range []string{"Cookie", "Authorization"} {
+		r := httptest.NewRequest(http.MethodGet, "/private", nil)
+		r.Header.Set(header, "secret")
+		m.serveCached(httptest.NewRecorder(), r, serve)
+	}
+	if len(m.responseCache.entries) != 0 {
+		t.Fatalf("credentialed responses were cached: %d entries", len(m.responseCache.entries))
+	}
+
+	m.serveCached(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/private", nil), serve)
+	m.serveCached(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/private", nil), serve)
+	if runs != 3 {
+		t.Errorf("script ran %d times, want 3 (two credentialed, one anonymous miss)", runs)
+	}
+}
+
+func TestServeCachedStripsPerRequestHeaders(t *testing.T) {
+	m := newTestCacheMiddleware()
+	m.requestIDHeader = "X-Request-ID"
+	serve := func(w http.ResponseWriter) {
+		w.Header().Set("Server-Timing", "frango-env;dur=1.00")
+		w.Header().Set("X-Request-ID", "first")
+		w.Header().Set(CacheTagHeader, "page")
+		w.Write([]byte("hello"))
+	}
+
+	first := httptest.NewRecorder()
+	m.serveCached(first, httptest.NewRequest(http.MethodGet, "/page", nil), serve)
+	if first.Header().Get("Server-Timing") == "" || first.Header().Get("X-Request-ID") != "first" {
+		t.Error("per-request headers missing from the response that produced them")
+	}
+	if first.Header().Get(CacheTagHeader) != "" {
+		t.Error("cache tag header leaked to the client")
+	}
+
+	replayed := httptest.NewRecorder()
+	m.serveCached(replayed, httptest.NewRequest(http.MethodGet, "/page", nil), func(w http.ResponseWriter) {
+		t.Error("cached response was not served from the cache")
+	})
+	if replayed.Body.String() != "hello" {
+		t.Errorf("replayed body = %q, want hello", replayed.Body.String())
+	}
+	for _, name := range []string{"Server-Timing", "X-Request-ID", CacheTagHeader} {
+		if value := replayed.Header().Get(name); value != "" {
+			t.Errorf("%s replayed from the cache: %q", name, value)
+		}
+	}
+	if m.InvalidateCacheTag("page") != 1 {
+		t.Error("stored response lost its tag")
+	}
+}
+
+// negotiatedPage answers with a body chosen from the Accept header, as WithContentTypes does
+func negotiatedPage(runs *int) func(r *http.Request) func(w http.ResponseWriter) {
+	return func(r *http.Request) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) {
+			*runs++
+			w.Header().Add("Vary", "Accept")
+			if r.Header.Get("Accept") == "application/json" {
+				w.Write([]byte(`{"page":1}`))
+				return
+			}
+			w.Write([]byte("<p>page 1</p>"))
+		}
+	}
+}
+
+func TestServeCachedHonorsVary(t *testing.T) {
+	m := newTestCacheMiddleware()
+	runs := 0
+	page := negotiatedPage(&runs)
+
+	get := func(accept string) string {
+		r := httptest.NewRequest(http.MethodGet, "/page", nil)
+		r.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		m.serveCached(recorder, r, page(r))
+		return recorder.Body.String()
+	}
+
+	if body := get("application/json"); body != `{"page":1}` {
+		t.Fatalf("JSON request got %q", body)
+	}
+	if body := get("text/html"); body != "<p>page 1</p>" {
+		t.Errorf("HTML request got %q, the JSON response was served from the cache", body)
+	}
+	if body := get("application/json"); body != `{"page":1}` {
+		t.Errorf("second JSON request got %q", body)
+	}
+	if body := get("text/html"); body != "<p>page 1</p>" {
+		t.Errorf("second HTML request got %q", body)
+	}
+	if runs != 2 {
+		t.Errorf("script ran %d times, want 2 (one per Accept value)", runs)
+	}
+}
+
+func TestServeCachedKeysByHost(t *testing.T) {
+	m := newTestCacheMiddleware()
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		recorder := httptest.NewRecorder()
+		m.serveCached(recorder, r, func(w http.ResponseWriter) {
+			w.Write([]byte(r.Host))
+		})
+		if recorder.Body.String() != host {
+			t.Errorf("%s got the page for %s", host, recorder.Body.String())
+		}
+	}
+}
+
+func TestServeCachedSkipsVaryStar(t *testing.T) {
+	m := newTestCacheMiddleware()
+	m.serveCached(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/page", nil), func(w http.ResponseWriter) {
+		w.Header().Set("Vary", "*")
+		w.Write([]byte("hello"))
+	})
+	if len(m.responseCache.entries) != 0 {
+		t.Error("a Vary: * response was cached")
+	}
+}
+
+func TestServeCachedVaryOnOuterHeaders(t *testing.T) {
+	m := newTestCacheMiddleware()
+	runs := 0
+	for _, origin := range []string{"https://a.example.com", "https://b.example.com", "https://a.example.com"} {
+		r := httptest.NewRequest(http.MethodGet, "/api", nil)
+		r.Header.Set("Origin", origin)
+		recorder := httptest.NewRecorder()
+		// CORS sets its Vary on the outer writer before PHP runs
+		recorder.Header().Add("Vary", "Origin")
+		m.serveCached(recorder, r, func(w http.ResponseWriter) {
+			runs++
+			w.Write([]byte(origin))
+		})
+		if recorder.Body.String() != origin {
+			t.Errorf("%s got the response for %s", origin, recorder.Body.String())
+		}
+	}
+	if runs != 2 {
+		t.Errorf("script ran %d times, want 2 (one per origin)", runs)
+	}
+	if len(m.responseCache.vary) != 1 {
+		t.Errorf("vary index holds %d URLs, want 1", len(m.responseCache.vary))
+	}
+}
@@ -0,0 +1,162 @@
+package frango
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressibleTypes are the content types worth compressing; others (images, archives,
+// fonts...) are usually compressed already
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+// WithCompression compresses PHP responses with gzip or deflate when the client accepts
+// it and the content type is compressible (text/*, JSON, JavaScript, XML, SVG). Responses
+// the script already encoded are left alone. level is a compress/flate level, e.g.
+// gzip.DefaultCompression or gzip.BestSpeed.
+func WithCompression(level int) Option {
+	return func(m *Middleware) {
+		m.compression = true
+		m.compressionLevel = level
+	}
+}
+
+// compressWriter compresses the response body once the headers show it's worthwhile
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	level       int
+	encoder     io.WriteCloser
+	wroteHeader bool
+}
+
+// newCompressWriter wraps w if the request accepts a supported encoding
+func (m *Middleware) newCompressWriter(w http.ResponseWriter, r *http.Request) *compressWriter {
+	if !m.compression || r.Method == http.MethodHead {
+		return nil
+	}
+	encoding := acceptedEncoding(r.Header.Values("Accept-Encoding"))
+	if encoding == "" {
+		return nil
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressWriter{ResponseWriter: w, encoding: encoding, level: m.compressionLevel}
+}
+
+// WriteHeader starts compression if the response qualifies
+func (c *compressWriter) WriteHeader(status int) {
+	if status >= http.StatusOK && !c.wroteHeader {
+		c.wroteHeader = true
+		c.start(status)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write compresses the body when compression started
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.encoder != nil {
+		return c.encoder.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+// Flush pushes compressed data written so far to the client
+func (c *compressWriter) Flush() {
+	if flusher, ok := c.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// start sets up the encoder for compressible responses
+func (c *compressWriter) start(status int) {
+	header := c.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || !isCompressible(header.Get("Content-Type")) {
+		return
+	}
+
+	var err error
+	switch c.encoding {
+	case "gzip":
+		c.encoder, err = gzip.NewWriterLevel(c.ResponseWriter, c.level)
+	case "deflate":
+		c.encoder, err = flate.NewWriter(c.ResponseWriter, c.level)
+	}
+	if err != nil {
+		c.encoder = nil
+		return
+	}
+
+	header.Set("Content-Encoding", c.encoding)
+	// The length PHP computed is for the uncompressed body
+	header.Del("Content-Length")
+}
+
+// close flushes the remaining compressed data
+func (c *compressWriter) close() {
+	if c.encoder != nil {
+		c.encoder.Close()
+	}
+}
+
+// isCompressible reports whether a content type benefits from compression
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(contentType, "+json") || strings.Contains(contentType, "+xml")
+}
+
+// acceptedEncoding picks gzip or deflate from Accept-Encoding, preferring gzip
+func acceptedEncoding(values []string) string {
+	qualities := make(map[string]float64)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			quality := 1.0
+			if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					quality = parsed
+				}
+			}
+			qualities[strings.ToLower(strings.TrimSpace(name))] = quality
+		}
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		quality, listed := qualities[encoding]
+		if !listed {
+			quality, listed = qualities["*"]
+		}
+		if listed && quality > 0 {
+			return encoding
+		}
+	}
+	return ""
+}
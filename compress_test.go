@@ -0,0 +1,119 @@
+package frango
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{"gzip"}, "gzip"},
+		{[]string{"deflate"}, "deflate"},
+		{[]string{"gzip, deflate, br"}, "gzip"},
+		{[]string{"deflate, gzip"}, "gzip"},
+		{[]string{"br"}, ""},
+		{[]string{"identity"}, ""},
+		{[]string{"GZIP"}, "gzip"},
+		{[]string{"gzip;q=0, deflate"}, "deflate"},
+		{[]string{"gzip; q=0.5"}, "gzip"},
+		{[]string{"gzip;q=0", "deflate;q=0"}, ""},
+		{[]string{"br", "deflate"}, "deflate"},
+		{[]string{"*"}, "gzip"},
+		{[]string{"*;q=0"}, ""},
+		{[]string{"gzip;q=0, *"}, "deflate"},
+	}
+	for _, tt := range tests {
+		if got := acceptedEncoding(tt.values); got != tt.want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+// compressedResponse writes body through a compressWriter with the given response headers
+func compressedResponse(t *testing.T, acceptEncoding string, header http.Header, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	m := &Middleware{compression: true, compressionLevel: gzip.DefaultCompression}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+
+	recorder := httptest.NewRecorder()
+	c := m.newCompressWriter(recorder, r)
+	if c == nil {
+		t.Fatalf("no compressWriter for Accept-Encoding %q", acceptEncoding)
+	}
+	for name, values := range header {
+		c.Header()[name] = values
+	}
+	c.Write([]byte(body))
+	c.close()
+	return recorder
+}
+
+func TestCompressWriter(t *testing.T) {
+	body := strings.Repeat("<p>hello</p>", 100)
+
+	recorder := compressedResponse(t, "gzip", http.Header{
+		"Content-Type":   {"text/html; charset=UTF-8"},
+		"Content-Length": {"1200"},
+	}, body)
+	if recorder.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", recorder.Header().Get("Content-Encoding"))
+	}
+	if recorder.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", recorder.Header().Get("Vary"))
+	}
+	if recorder.Header().Get("Content-Length") != "" {
+		t.Error("the uncompressed Content-Length was kept")
+	}
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(reader); string(decoded) != body {
+		t.Error("the gzipped body doesn't decode to the original")
+	}
+
+	recorder = compressedResponse(t, "deflate", http.Header{"Content-Type": {"application/json"}}, body)
+	if recorder.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("Content-Encoding = %q, want deflate", recorder.Header().Get("Content-Encoding"))
+	}
+	if decoded, _ := io.ReadAll(flate.NewReader(recorder.Body)); string(decoded) != body {
+		t.Error("the deflated body doesn't decode to the original")
+	}
+}
+
+func TestCompressWriterSkipsEncodedResponses(t *testing.T) {
+	for name, header := range map[string]http.Header{
+		"image":           {"Content-Type": {"image/png"}},
+		"archive":         {"Content-Type": {"application/zip"}},
+		"already encoded": {"Content-Type": {"text/plain"}, "Content-Encoding": {"br"}},
+	} {
+		recorder := compressedResponse(t, "gzip", header, "payload")
+		if encoding := recorder.Header().Get("Content-Encoding"); encoding == "gzip" {
+			t.Errorf("%s: response was compressed again", name)
+		}
+		if recorder.Body.String() != "payload" {
+			t.Errorf("%s: body = %q, want it untouched", name, recorder.Body.String())
+		}
+	}
+
+	m := &Middleware{compression: true}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if m.newCompressWriter(httptest.NewRecorder(), r) != nil {
+		t.Error("compressing for a client that sent no Accept-Encoding")
+	}
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Method = http.MethodHead
+	if m.newCompressWriter(httptest.NewRecorder(), r) != nil {
+		t.Error("compressing a HEAD response")
+	}
+}
@@ -0,0 +1,88 @@
+package frango
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures cross-origin access to PHP routes
+type CORSOptions struct {
+	// AllowedOrigins lists origins allowed to call the routes; "*" allows any
+	AllowedOrigins []string
+	// AllowedMethods lists methods allowed in preflight requests (default GET, POST, HEAD)
+	AllowedMethods []string
+	// AllowedHeaders lists request headers allowed in preflight requests
+	AllowedHeaders []string
+	// AllowCredentials allows cookies and credentials on cross-origin requests
+	AllowCredentials bool
+	// MaxAge is how many seconds browsers may cache a preflight response (0 omits it)
+	MaxAge int
+}
+
+// WithCORS answers CORS preflight (OPTIONS) requests to PHP routes with 204 No Content
+// and the configured Access-Control-Allow-* headers, without running PHP, and adds
+// Access-Control-Allow-Origin to actual responses for allowed origins
+func WithCORS(options CORSOptions) Option {
+	return func(m *Middleware) {
+		if len(options.AllowedMethods) == 0 {
+			options.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+		}
+		m.cors = &options
+	}
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for a request origin
+func (c *CORSOptions) allowedOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" && !c.AllowCredentials {
+			return "*"
+		}
+		// Credentials can't be combined with a wildcard, so echo the origin instead
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// handleCORS adds CORS headers and answers preflight requests. It returns true when
+// the request has been fully handled.
+func (m *Middleware) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	if m.cors == nil {
+		return false
+	}
+
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	header := w.Header()
+	header.Add("Vary", "Origin")
+
+	origin := r.Header.Get("Origin")
+	allowOrigin := ""
+	if origin != "" {
+		allowOrigin = m.cors.allowedOrigin(origin)
+	}
+	if allowOrigin != "" {
+		header.Set("Access-Control-Allow-Origin", allowOrigin)
+		if m.cors.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		return false
+	}
+
+	header.Set("Allow", strings.Join(append([]string{http.MethodOptions}, m.cors.AllowedMethods...), ", "))
+	if allowOrigin != "" {
+		header.Set("Access-Control-Allow-Methods", strings.Join(m.cors.AllowedMethods, ", "))
+		if len(m.cors.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(m.cors.AllowedHeaders, ", "))
+		}
+		if m.cors.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(m.cors.MaxAge))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,29 @@
+package frango
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// addDeadlineEnv passes the time left before the request context's deadline to PHP,
+// which applies it with set_time_limit so the script stops itself before Go gives up
+// on the request. A lower max_execution_time configured through WithPHPIni still wins.
+func (m *Middleware) addDeadlineEnv(r *http.Request, env map[string]string) {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return
+	}
+
+	// PHP counts whole seconds and 0 means unlimited, so never go below 1
+	seconds := int(math.Ceil(time.Until(deadline).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	if configured, err := strconv.Atoi(m.phpIni["max_execution_time"]); err == nil && configured > 0 && configured < seconds {
+		seconds = configured
+	}
+
+	env["frango_MAX_EXECUTION_TIME"] = strconv.Itoa(seconds)
+}
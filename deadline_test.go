@@ -0,0 +1,45 @@
+package frango
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadlineBecomesMaxExecutionTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ini     string
+		want    string
+	}{
+		{"no deadline", 0, "", ""},
+		{"rounded up", 2500 * time.Millisecond, "", "3"},
+		{"already passed", -time.Second, "", "1"},
+		{"lower ini wins", 30 * time.Second, "10", "10"},
+		{"higher ini ignored", 5 * time.Second, "60", "5"},
+		{"unlimited ini ignored", 5 * time.Second, "0", "5"},
+	}
+	for _, tt := range tests {
+		m := &Middleware{logger: log.New(io.Discard, "", 0), phpIni: map[string]string{}}
+		if tt.ini != "" {
+			m.phpIni["max_execution_time"] = tt.ini
+		}
+
+		r := httptest.NewRequest("GET", "/slow.php", nil)
+		if tt.timeout != 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), tt.timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		env := make(map[string]string)
+		m.addDeadlineEnv(r, env)
+		if got, found := env["frango_MAX_EXECUTION_TIME"]; got != tt.want || found != (tt.want != "") {
+			t.Errorf("%s: frango_MAX_EXECUTION_TIME = %q (set %t), want %q", tt.name, got, found, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package frango
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EnvDebug describes how a PHP script is resolved and which environments run it
+type EnvDebug struct {
+	// ScriptPath is the absolute path of the script in the source directory
+	ScriptPath string
+	// ScriptRelPath is the script's path relative to the source directory
+	ScriptRelPath string
+	// Hash is the SHA-256 of the script's current source content
+	Hash string
+	// InPlace is true when the script runs from the source directory (worker
+	// scripts and read-only mode) rather than from a mirrored environment
+	InPlace bool
+	// Environments lists the cached environments serving the script, by endpoint
+	Environments []EnvironmentDebug
+}
+
+// EnvironmentDebug describes one cached environment serving a script
+type EnvironmentDebug struct {
+	// ID is the environment's identifier (DEBUG_ENV_ID in PHP)
+	ID string
+	// EndpointPath is the URL path the environment was created for
+	EndpointPath string
+	// TempPath is the environment's temporary directory
+	TempPath string
+	// MirroredPath is the script's copy inside the environment
+	MirroredPath string
+	// MirroredHash is the SHA-256 of the mirrored copy, empty if not mirrored yet
+	MirroredHash string
+	// LastUpdated is when the environment was last rebuilt
+	LastUpdated time.Time
+}
+
+// DebugInfo reports how a script (relative to the source directory, or absolute) is
+// resolved: its source path and hash and the environments mirroring it. A mirrored hash
+// that differs from the source hash means the environment hasn't been rebuilt yet.
+func (m *Middleware) DebugInfo(scriptPath string) (EnvDebug, error) {
+	absPath := m.absScriptPath(scriptPath)
+
+	relPath, err := filepath.Rel(m.sourceDir, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(os.PathSeparator)) {
+		return EnvDebug{}, fmt.Errorf("script %s is outside the source directory %s", scriptPath, m.sourceDir)
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return EnvDebug{}, fmt.Errorf("error reading script %s: %w", absPath, err)
+	}
+
+	info := EnvDebug{
+		ScriptPath:    absPath,
+		ScriptRelPath: filepath.ToSlash(relPath),
+		Hash:          fileHash(content),
+		InPlace:       m.readOnlySource || m.workerScripts[absPath],
+	}
+	m.envCache.mutex.RLock()
+	var environments []*PHPEnvironment
+	for _, env := range m.envCache.environments {
+		if env.OriginalPath == absPath {
+			environments = append(environments, env)
+		}
+	}
+	m.envCache.mutex.RUnlock()
+
+	for _, env := range environments {
+		env.mutex.RLock()
+		info.Environments = append(info.Environments, EnvironmentDebug{
+			ID:           env.ID,
+			EndpointPath: env.EndpointPath,
+			TempPath:     env.TempPath,
+			MirroredPath: filepath.Join(env.TempPath, relPath),
+			MirroredHash: env.fileHashes[relPath],
+			LastUpdated:  env.LastUpdated,
+		})
+		env.mutex.RUnlock()
+	}
+
+	sort.Slice(info.Environments, func(i, j int) bool {
+		return info.Environments[i].EndpointPath < info.Environments[j].EndpointPath
+	})
+	return info, nil
+}
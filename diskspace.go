@@ -0,0 +1,35 @@
+package frango
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// envFileOps are the filesystem writes that build an environment, replaceable in tests
+// to simulate a full disk
+type envFileOps struct {
+	mkdirAll  func(path string, perm os.FileMode) error
+	writeFile func(name string, data []byte, perm os.FileMode) error
+}
+
+// osFileOps writes environments to the real filesystem
+var osFileOps = envFileOps{mkdirAll: os.MkdirAll, writeFile: os.WriteFile}
+
+// isDiskFull reports whether err was caused by the disk running out of space
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// environmentError answers a request whose environment couldn't be built. A full
+// disk is a temporary condition, so it gets a 503 with Retry-After instead of a 500.
+func (m *Middleware) environmentError(w http.ResponseWriter, err error) {
+	if isDiskFull(err) {
+		m.logger.Printf("WARNING: No space left on device for PHP environments in %s", m.tempDir)
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "Service temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "Server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,105 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// fillDisk makes the cache's file writes fail with ENOSPC until the returned
+// function is called
+func fillDisk(cache *EnvironmentCache) (free func()) {
+	var full atomic.Bool
+	full.Store(true)
+	cache.fileOps = envFileOps{
+		mkdirAll: os.MkdirAll,
+		writeFile: func(name string, data []byte, perm os.FileMode) error {
+			if full.Load() {
+				return &os.PathError{Op: "write", Path: name, Err: syscall.ENOSPC}
+			}
+			return os.WriteFile(name, data, perm)
+		},
+	}
+	return func() { full.Store(false) }
+}
+
+func TestFullDiskEnvironmentIsNotCached(t *testing.T) {
+	cache := newTestEnvironmentCache(t, "page.php")
+	free := fillDisk(cache)
+	scriptPath := filepath.Join(cache.sourceDir, "page.php")
+
+	if _, err := cache.GetEnvironment("/page", scriptPath); !isDiskFull(err) {
+		t.Fatalf("GetEnvironment() error = %v, want a full disk", err)
+	}
+	if len(cache.environments) != 0 {
+		t.Fatalf("a broken environment was cached: %v", cache.environments)
+	}
+	if entries, _ := os.ReadDir(cache.baseDir); len(entries) != 0 {
+		t.Errorf("a broken environment left %d directories behind", len(entries))
+	}
+
+	free()
+	env, err := cache.GetEnvironment("/page", scriptPath)
+	if err != nil {
+		t.Fatalf("retry after freeing space: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(env.TempPath, "page.php")); err != nil {
+		t.Errorf("retried environment is missing the script: %v", err)
+	}
+}
+
+func TestFullDiskRebuildDiscardsEnvironment(t *testing.T) {
+	cache := newTestEnvironmentCache(t, "page.php")
+	cache.developmentMode = true
+	scriptPath := filepath.Join(cache.sourceDir, "page.php")
+
+	broken, err := cache.GetEnvironment("/page", scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(scriptPath, []byte("<?php echo 'v2';"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	free := fillDisk(cache)
+	if _, err := cache.GetEnvironment("/page", scriptPath); !isDiskFull(err) {
+		t.Fatalf("rebuild error = %v, want a full disk", err)
+	}
+	if _, cached := cache.environments["/page"]; cached {
+		t.Fatal("a half-rebuilt environment is still cached")
+	}
+
+	free()
+	env, err := cache.GetEnvironment("/page", scriptPath)
+	if err != nil {
+		t.Fatalf("retry after freeing space: %v", err)
+	}
+	if env == broken {
+		t.Error("retry reused the half-rebuilt environment")
+	}
+	if content, _ := os.ReadFile(filepath.Join(env.TempPath, "page.php")); string(content) != "<?php echo 'v2';" {
+		t.Errorf("retried environment serves %q, want the new script", content)
+	}
+}
+
+func TestEnvironmentErrorStatus(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+
+	recorder := httptest.NewRecorder()
+	m.environmentError(recorder, &os.PathError{Op: "write", Path: "page.php", Err: syscall.ENOSPC})
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") == "" {
+		t.Errorf("full disk = %d with Retry-After %q, want 503 with Retry-After", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+
+	recorder = httptest.NewRecorder()
+	m.environmentError(recorder, os.ErrPermission)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("other error = %d, want 500", recorder.Code)
+	}
+}
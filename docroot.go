@@ -0,0 +1,47 @@
+package frango
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Document root strategies for WithDocumentRootStrategy
+const (
+	// DocumentRootScriptDir sets DOCUMENT_ROOT to the directory of the running script
+	DocumentRootScriptDir = "script-dir"
+	// DocumentRootEnvironment sets DOCUMENT_ROOT to the root of the script's
+	// environment, the mirrored copy of the whole source directory
+	DocumentRootEnvironment = "env-root"
+	// DocumentRootSource sets DOCUMENT_ROOT to the original source directory
+	DocumentRootSource = "source-dir"
+)
+
+// WithDocumentRootStrategy chooses what $_SERVER['DOCUMENT_ROOT'] points at, for apps
+// that include files relative to it. strategy is DocumentRootScriptDir (the default
+// when empty), DocumentRootEnvironment, so includes resolve from the project root
+// inside the environment, or DocumentRootSource. Scripts are still located from
+// their own directory whatever the strategy.
+func WithDocumentRootStrategy(strategy string) Option {
+	return func(m *Middleware) {
+		m.documentRootStrategy = strategy
+	}
+}
+
+// phpDocumentRoot returns the DOCUMENT_ROOT exposed to PHP for a script at
+// phpFilePath, relPath being its path relative to the source directory
+func (m *Middleware) phpDocumentRoot(phpFilePath, relPath string) string {
+	switch m.documentRootStrategy {
+	case DocumentRootEnvironment:
+		// The script sits at relPath under its environment root, or under the
+		// source directory itself for workers and read-only mode
+		root := strings.TrimSuffix(phpFilePath, string(filepath.Separator)+relPath)
+		if root == phpFilePath {
+			return filepath.Dir(phpFilePath)
+		}
+		return root
+	case DocumentRootSource:
+		return m.sourceDir
+	default:
+		return filepath.Dir(phpFilePath)
+	}
+}
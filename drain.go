@@ -0,0 +1,56 @@
+package frango
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultShutdownTimeout is how long Shutdown waits for in-flight PHP requests
+const defaultShutdownTimeout = 10 * time.Second
+
+// WithShutdownTimeout sets how long Shutdown waits for in-flight PHP requests to
+// finish before stopping FrankenPHP and removing the temp directories (10 seconds by
+// default). Requests arriving during shutdown get 503 Service Unavailable.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(m *Middleware) {
+		m.shutdownTimeout = timeout
+	}
+}
+
+// beginRequest registers an in-flight PHP request. It returns false, after answering
+// with 503, when the instance is shutting down; otherwise the caller must call
+// m.endRequest when done.
+func (m *Middleware) beginRequest(w http.ResponseWriter) bool {
+	// Count first so Shutdown either sees this request or we see its flag
+	m.activeRequests.Add(1)
+	if m.shuttingDown.Load() {
+		m.activeRequests.Add(-1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// endRequest unregisters an in-flight PHP request
+func (m *Middleware) endRequest() {
+	m.activeRequests.Add(-1)
+}
+
+// drainRequests stops accepting PHP requests and waits for in-flight ones to finish,
+// up to the shutdown timeout
+func (m *Middleware) drainRequests() {
+	m.shuttingDown.Store(true)
+
+	deadline := time.Now().Add(m.shutdownTimeout)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for m.activeRequests.Load() > 0 {
+		if time.Now().After(deadline) {
+			m.logger.Printf("Shutting down with %d PHP requests still running after %s", m.activeRequests.Load(), m.shutdownTimeout)
+			return
+		}
+		<-ticker.C
+	}
+}
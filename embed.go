@@ -0,0 +1,167 @@
+package frango
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ForEmbed returns a handler that runs a PHP file from an embed.FS. The file is
+// extracted into the source directory under embedPath on the first call; later
+// calls for the same embedPath reuse the extracted copy.
+func (m *Middleware) ForEmbed(embedFS embed.FS, embedPath string) http.Handler {
+	targetPath := m.extractEmbed(embedFS, embedPath)
+	if targetPath == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Server error", http.StatusInternalServerError)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.serveScript(targetPath, w, r)
+	})
+}
+
+// extractEmbed writes an embedded file into the source directory once and returns its path
+func (m *Middleware) extractEmbed(embedFS embed.FS, embedPath string) string {
+	m.embedMutex.Lock()
+	defer m.embedMutex.Unlock()
+
+	if targetPath, exists := m.embeddedScripts[embedPath]; exists {
+		return targetPath
+	}
+
+	targetPath := m.AddEmbeddedLibrary(embedFS, embedPath, filepath.ToSlash(embedPath))
+	if targetPath != "" {
+		m.embeddedScripts[embedPath] = targetPath
+	}
+	return targetPath
+}
+
+// HandleEmbedDir extracts a whole directory tree from an embedded filesystem (embed.FS
+// or any fs.FS) into the source directory under root, then registers its PHP files under
+// prefix like HandleDir. Non-PHP files are extracted too, so includes and assets resolve.
+func (m *Middleware) HandleEmbedDir(prefix string, embedFS fs.FS, root string) error {
+	_, err := m.handleEmbedDir(prefix, embedFS, root)
+	return err
+}
+
+// handleEmbedDir extracts and registers an embedded tree like HandleEmbedDir and
+// returns the URL patterns it registered
+func (m *Middleware) handleEmbedDir(prefix string, embedFS fs.FS, root string) ([]string, error) {
+	root = filepath.ToSlash(root)
+
+	count := 0
+	err := fs.WalkDir(embedFS, root, func(embedPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		content, err := fs.ReadFile(embedFS, embedPath)
+		if err != nil {
+			return fmt.Errorf("error reading embedded file %s: %w", embedPath, err)
+		}
+		if _, err := m.writeLibrary(content, embedPath); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error extracting embedded directory %s: %w", root, err)
+	}
+
+	m.logger.Printf("Extracted %d embedded files from %s", count, root)
+	return m.handleDir(prefix, filepath.FromSlash(root))
+}
+
+// AddEmbeddedLibraryDir adds every file under embedDir in an embed.FS as a library at
+// the same relative path under targetPrefix in the source directory, like calling
+// AddEmbeddedLibrary for each, and returns the paths written
+func (m *Middleware) AddEmbeddedLibraryDir(embedFS embed.FS, embedDir string, targetPrefix string) ([]string, error) {
+	embedDir = path.Clean(filepath.ToSlash(embedDir))
+
+	var targetPaths []string
+	err := fs.WalkDir(embedFS, embedDir, func(embedPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		content, err := embedFS.ReadFile(embedPath)
+		if err != nil {
+			return fmt.Errorf("error reading embedded library file %s: %w", embedPath, err)
+		}
+
+		relPath := strings.TrimPrefix(strings.TrimPrefix(embedPath, embedDir), "/")
+		targetPath, err := m.writeLibrary(content, path.Join(targetPrefix, relPath))
+		if err != nil {
+			return err
+		}
+		targetPaths = append(targetPaths, targetPath)
+		return nil
+	})
+	if err != nil {
+		return targetPaths, fmt.Errorf("error adding embedded library directory %s: %w", embedDir, err)
+	}
+
+	m.logger.Printf("Added %d embedded PHP libraries from %s under %s", len(targetPaths), embedDir, targetPrefix)
+	return targetPaths, nil
+}
+
+// ServeDirectory registers every PHP file under scanDir like HandleDir and returns a
+// handler serving just that tree under urlPrefix: its scripts with clean URLs and its
+// directory index. With a nil fsys, scanDir is a directory on
+// disk (relative to the source directory unless absolute); otherwise it's extracted
+// from fsys first, as HandleEmbedDir does. Any other request, including other routes
+// and files of the middleware, gets 404.
+func (m *Middleware) ServeDirectory(fsys fs.FS, scanDir string, urlPrefix string) (http.Handler, error) {
+	var patterns []string
+	var err error
+	if fsys != nil {
+		patterns, err = m.handleEmbedDir(urlPrefix, fsys, scanDir)
+	} else {
+		patterns, err = m.handleDir(urlPrefix, scanDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		tree[pattern] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.inTree(tree, r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		m.ServeHTTP(w, r)
+	}), nil
+}
+
+// inTree reports whether ServeHTTP would route urlPath to one of the tree's patterns,
+// as is, with a trailing slash or with a PHP extension
+func (m *Middleware) inTree(tree map[string]bool, urlPath string) bool {
+	cleaned := path.Clean("/" + urlPath)
+	if strings.HasSuffix(urlPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	if tree[cleaned] || (!strings.HasSuffix(cleaned, "/") && tree[cleaned+"/"]) {
+		return true
+	}
+	for _, ext := range m.phpExtensions {
+		if tree[cleaned+ext] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package frango
+
+import "os"
+
+// WithEnvPassthrough forwards the named process environment variables (e.g.
+// DATABASE_URL) to PHP, where they appear in $_SERVER.
+// Variables that aren't set are skipped.
+func WithEnvPassthrough(keys ...string) Option {
+	return func(m *Middleware) {
+		m.envPassthrough = append(m.envPassthrough, keys...)
+	}
+}
+
+// WithEnv passes static environment variables to every PHP script
+func WithEnv(env map[string]string) Option {
+	return func(m *Middleware) {
+		for key, value := range env {
+			m.staticEnv[key] = value
+		}
+	}
+}
+
+// addConfiguredEnv adds the static and passthrough variables to a PHP environment.
+// Variables frango already set for the request take precedence.
+func (m *Middleware) addConfiguredEnv(phpEnv map[string]string) {
+	for key, value := range m.staticEnv {
+		if _, exists := phpEnv[key]; !exists {
+			phpEnv[key] = value
+		}
+	}
+
+	for _, key := range m.envPassthrough {
+		if _, exists := phpEnv[key]; exists {
+			continue
+		}
+		if value, found := os.LookupEnv(key); found {
+			phpEnv[key] = value
+		}
+	}
+}
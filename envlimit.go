@@ -0,0 +1,68 @@
+package frango
+
+import (
+	"sort"
+	"strings"
+)
+
+// envDropOrder lists the prefixes of injected variables that can be dropped when
+// the environment exceeds its limits, lowest priority first. Each has a fallback:
+// DEBUG_ vars are diagnostics only, query params stay in QUERY_STRING and $_GET,
+// and path params stay in PATH_PARAMS.
+var envDropOrder = []string{"DEBUG_", "QUERY_PARAM_", "PATH_PARAM_"}
+
+// WithMaxEnvVars caps the environment frango injects into each PHP request to maxCount
+// variables and maxBytes total bytes (keys plus values); 0 disables a limit.
+// Over the limit, DEBUG_*, then QUERY_PARAM_*, then PATH_PARAM_* variables are dropped
+// with a warning instead of failing the request.
+func WithMaxEnvVars(maxCount, maxBytes int) Option {
+	return func(m *Middleware) {
+		m.maxEnvVars = maxCount
+		m.maxEnvBytes = maxBytes
+	}
+}
+
+// limitEnv drops low-priority variables until env fits the configured limits
+func (m *Middleware) limitEnv(env map[string]string) {
+	if m.maxEnvVars <= 0 && m.maxEnvBytes <= 0 {
+		return
+	}
+
+	size := 0
+	for key, value := range env {
+		size += len(key) + len(value)
+	}
+	withinLimits := func() bool {
+		return (m.maxEnvVars <= 0 || len(env) <= m.maxEnvVars) &&
+			(m.maxEnvBytes <= 0 || size <= m.maxEnvBytes)
+	}
+	if withinLimits() {
+		return
+	}
+
+	originalCount, originalSize := len(env), size
+	for _, prefix := range envDropOrder {
+		// Sort so the same request always keeps the same variables
+		var keys []string
+		for key := range env {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+		for _, key := range keys {
+			if withinLimits() {
+				break
+			}
+			size -= len(key) + len(env[key])
+			delete(env, key)
+		}
+	}
+
+	m.logger.Printf("WARNING: PHP environment exceeded limits (%d vars, %d bytes); dropped %d vars, now %d vars, %d bytes",
+		originalCount, originalSize, originalCount-len(env), len(env), size)
+	if !withinLimits() {
+		m.logger.Printf("WARNING: PHP environment still exceeds limits after dropping optional variables")
+	}
+}
@@ -0,0 +1,76 @@
+package frango
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLimitEnvDropsOptionalVariablesFirst(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	WithMaxEnvVars(8, 0)(m)
+
+	env := map[string]string{
+		"REQUEST_METHOD":    "GET",
+		"QUERY_STRING":      "a=1&b=2",
+		"SCRIPT_NAME":       "/index.php",
+		"DEBUG_URL_PATH":    "/",
+		"DEBUG_ENV_ID":      "env",
+		"QUERY_PARAM_A":     "1",
+		"QUERY_PARAM_B":     "2",
+		"PATH_PARAM_ID":     "42",
+		"PATH_PARAMS":       `{"id":"42"}`,
+		"frango_REQUEST_ID": "abc",
+	}
+	m.limitEnv(env)
+
+	if len(env) != 8 {
+		t.Fatalf("env has %d vars, want 8: %v", len(env), env)
+	}
+	for key := range env {
+		if strings.HasPrefix(key, "DEBUG_") {
+			t.Errorf("%s kept while query params were dropped", key)
+		}
+	}
+	for _, key := range []string{"REQUEST_METHOD", "QUERY_STRING", "SCRIPT_NAME", "PATH_PARAM_ID", "PATH_PARAMS", "frango_REQUEST_ID"} {
+		if _, ok := env[key]; !ok {
+			t.Errorf("%s was dropped", key)
+		}
+	}
+}
+
+func TestLimitEnvByteLimit(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	WithMaxEnvVars(0, 100)(m)
+
+	env := map[string]string{"REQUEST_METHOD": "GET"}
+	for i := 0; i < 50; i++ {
+		env[fmt.Sprintf("QUERY_PARAM_P%d", i)] = "value"
+	}
+	m.limitEnv(env)
+
+	size := 0
+	for key, value := range env {
+		size += len(key) + len(value)
+	}
+	if size > 100 {
+		t.Errorf("env is %d bytes, want at most 100", size)
+	}
+	if env["REQUEST_METHOD"] != "GET" {
+		t.Error("REQUEST_METHOD was dropped")
+	}
+}
+
+func TestLimitEnvDisabled(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	env := map[string]string{}
+	for i := 0; i < 5000; i++ {
+		env[fmt.Sprintf("QUERY_PARAM_P%d", i)] = "value"
+	}
+	m.limitEnv(env)
+	if len(env) != 5000 {
+		t.Errorf("env has %d vars without a limit, want 5000", len(env))
+	}
+}
@@ -0,0 +1,34 @@
+package frango
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// WithETag buffers successful GET and HEAD responses, tags them with a weak ETag
+// hashed from the body (unless the script set its own) and answers requests whose
+// If-None-Match matches with 304 Not Modified. PHP still runs, but unchanged pages
+// aren't resent. Buffering defeats streaming and flush() for these responses.
+func WithETag(enabled bool) Option {
+	return func(m *Middleware) {
+		m.etags = enabled
+	}
+}
+
+// applyETag tags a buffered response and reports whether the client's cached copy
+// is still current
+func (m *Middleware) applyETag(r *http.Request, buffered *bufferedResponse) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || buffered.statusCode() != http.StatusOK {
+		return false
+	}
+
+	etag := buffered.Header().Get("ETag")
+	if etag == "" {
+		// The body may be compressed afterwards, so the tag is weak
+		sum := sha256.Sum256(buffered.body.Bytes())
+		etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		buffered.Header().Set("ETag", etag)
+	}
+	return notModified(r, ResponseMeta{ETag: etag})
+}
@@ -0,0 +1,108 @@
+package frango
+
+import (
+	"net/http"
+	"time"
+)
+
+// EventType identifies a lifecycle event
+type EventType string
+
+const (
+	// EventRequestStarted is emitted when frango starts serving a PHP route
+	EventRequestStarted EventType = "request_started"
+	// EventRequestCompleted is emitted when a PHP route has been served
+	EventRequestCompleted EventType = "request_completed"
+	// EventEnvCreated is emitted when an isolated environment is built for an endpoint
+	EventEnvCreated EventType = "env_created"
+	// EventEnvRebuilt is emitted when an environment is refreshed after a file change
+	EventEnvRebuilt EventType = "env_rebuilt"
+	// EventEnvEvicted is emitted when a broken or least recently used environment is discarded
+	EventEnvEvicted EventType = "env_evicted"
+	// EventPHPError is emitted when a PHP script can't be served
+	EventPHPError EventType = "php_error"
+)
+
+// eventBufferSize is the capacity of the events channel; events are dropped when it's full
+const eventBufferSize = 256
+
+// FrangoEvent describes something that happened inside frango. Fields that don't
+// apply to an event type are left empty.
+type FrangoEvent struct {
+	Type EventType
+	Time time.Time
+	// Path is the URL path of the request or the environment's endpoint
+	Path string
+	// ScriptPath is the source script being served
+	ScriptPath string
+	// EnvID identifies the environment for env events
+	EnvID string
+	// Status is the response status for EventRequestCompleted
+	Status int
+	// Duration is how long the request took for EventRequestCompleted
+	Duration time.Duration
+	// Err is the failure for EventPHPError
+	Err error
+}
+
+// Events returns a channel of lifecycle events, e.g. for integration tests or tooling.
+// Events are only recorded once Events has been called. Sending never blocks: events
+// are dropped while the channel's buffer is full.
+func (m *Middleware) Events() <-chan FrangoEvent {
+	m.eventsEnabled.Store(true)
+	return m.events
+}
+
+// emit sends an event without blocking if anyone is listening
+func (m *Middleware) emit(event FrangoEvent) {
+	if !m.eventsEnabled.Load() {
+		return
+	}
+	event.Time = time.Now()
+	select {
+	case m.events <- event:
+	default:
+	}
+}
+
+// statusRecorder remembers the status code written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the first final status code
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 && status >= http.StatusOK {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write records an implicit 200 OK
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+// Flush passes PHP flush() calls through to the underlying writer
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// statusCode returns the recorded status, defaulting to 200
+func (s *statusRecorder) statusCode() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
@@ -39,30 +39,21 @@ func main() {
 	}
 	defer php.Shutdown()
 
-	// Add the PHP files from embedded filesystem
-	indexPath := php.AddFromEmbed("/index.php", indexPhp, "php/index.php")
-	userPath := php.AddFromEmbed("/api/user.php", userPhp, "php/api/user.php")
-	itemsPath := php.AddFromEmbed("/api/items.php", itemsPhp, "php/api/items.php")
-
-	// Explicitly register additional routes for these files
-	php.HandlePHP("/", indexPath)      // Root path
-	php.HandlePHP("/index", indexPath) // Without .php extension
-
-	php.HandlePHP("/api/user", userPath)
-	php.HandlePHP("/api/items", itemsPath)
-
 	// Create a standard HTTP mux for routing
 	mux := http.NewServeMux()
 
+	// Serve the PHP files straight from the embedded filesystem
+	mux.Handle("/{$}", php.ForEmbed(indexPhp, "php/index.php"))
+	mux.Handle("/index", php.ForEmbed(indexPhp, "php/index.php"))
+	mux.Handle("/api/user", php.ForEmbed(userPhp, "php/api/user.php"))
+	mux.Handle("/api/items", php.ForEmbed(itemsPhp, "php/api/items.php"))
+
 	// Register a custom Go handler
 	mux.HandleFunc("/api/time", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"time": "` + time.Now().Format(time.RFC3339) + `", "source": "go"}`))
 	})
 
-	// PHP middleware handles everything first, falls back to mux
-	handler := php.Wrap(mux)
-
 	// Setup graceful shutdown
 	go func() {
 		sigChan := make(chan os.Signal, 1)
@@ -76,7 +67,7 @@ func main() {
 	// Start server with our handler
 	log.Printf("Embed example server starting on port %s", *port)
 	log.Printf("Open http://localhost:%s/ in your browser", *port)
-	if err := http.ListenAndServe(":"+*port, handler); err != nil {
+	if err := http.ListenAndServe(":"+*port, mux); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
@@ -31,6 +31,17 @@ func main() {
 	}
 	defer php.Shutdown()
 
+	// A separate instance for the /php/ mount, so its scripts see URLs with the prefix
+	mounted, err := frango.New(
+		frango.WithSourceDir(webDir),
+		frango.WithDevelopmentMode(true),
+		frango.WithBasePath("/php"),
+	)
+	if err != nil {
+		log.Fatalf("Error creating mounted PHP middleware: %v", err)
+	}
+	defer mounted.Shutdown()
+
 	// Register some PHP endpoints
 	php.HandlePHP("/api/users", "api/users.php")
 	php.HandlePHP("/api/items", "api/items.php")
@@ -56,7 +67,7 @@ func main() {
 	mux.Handle("/api/", php.Wrap(http.NotFoundHandler()))
 
 	// Alternatively, mount the PHP middleware directly for a dedicated path
-	mux.Handle("/php/", http.StripPrefix("/php", php))
+	mux.Handle("/php/", http.StripPrefix("/php", mounted))
 
 	// For the root path, check PHP first, then fall back to a welcome page
 	mux.Handle("/", php.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
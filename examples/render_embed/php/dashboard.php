@@ -17,41 +17,10 @@ echo "HTTP_ Marker: " . ($_SERVER['HTTP_DEBUG_FRANGO_MARKER'] ?? 'Not found') .
 echo "PHP_ Marker: " . ($_ENV['PHP_DEBUG_FRANGO_MARKER'] ?? 'Not found') . "<br>";
 echo "</pre>";
 
-// Try multiple approaches to get variables
-function getVar($name, $default = []) {
-    // Try multiple places where variables could be
-    if (isset($_ENV['frango_VAR_' . $name])) {
-        return json_decode($_ENV['frango_VAR_' . $name], true);
-    } 
-    if (isset($_SERVER['frango_VAR_' . $name])) {
-        return json_decode($_SERVER['frango_VAR_' . $name], true);
-    }
-    if (isset($_SERVER['HTTP_FRANGO_VAR_' . $name])) {
-        return json_decode($_SERVER['HTTP_FRANGO_VAR_' . $name], true);
-    }
-    if (isset($_ENV['PHP_frango_VAR_' . $name])) {
-        return json_decode($_ENV['PHP_frango_VAR_' . $name], true);
-    }
-    if (isset($_SERVER[$name])) {
-        return json_decode($_SERVER[$name], true);
-    }
-    
-    // Try uppercase keys
-    $name_upper = strtoupper($name);
-    if (isset($_ENV[$name_upper])) {
-        return json_decode($_ENV[$name_upper], true);
-    }
-    if (isset($_SERVER[$name_upper])) {
-        return json_decode($_SERVER[$name_upper], true);
-    }
-    
-    return $default;
-}
-
-// Get data using our improved function
-$userData = getVar('user', []);
-$itemsData = getVar('items', []);
-$statsData = getVar('stats', []);
+// Get render data decoded from JSON
+$userData = frango_var('user', []);
+$itemsData = frango_var('items', []);
+$statsData = frango_var('stats', []);
 
 // Get username or default
 $username = htmlspecialchars($userData['name'] ?? 'Guest');
@@ -0,0 +1,119 @@
+package frango
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+)
+
+// executeScript runs a PHP script outside the route table against r, writing its
+// response to w. Relative script paths are resolved against the source directory.
+// Render data, if any, is passed to PHP the same way HandleRender does.
+func (m *Middleware) executeScript(r *http.Request, scriptPath string, data map[string]interface{}, w http.ResponseWriter) (err error) {
+	defer m.recoverPanicError(scriptPath, &err)
+
+	if err := m.ensureInitialized(r.Context()); err != nil {
+		return fmt.Errorf("error initializing PHP: %w", err)
+	}
+
+	scriptPath, urlPath, err := m.scriptURLPath(scriptPath)
+	if err != nil {
+		return err
+	}
+
+	pathParams := make(map[string]string)
+	if data != nil {
+		m.addPushHints(w, data)
+		if pathParams, err = m.renderParams(data); err != nil {
+			return err
+		}
+	}
+
+	m.servePHPFileWithPathParams(urlPath, scriptPath, pathParams, w, r)
+	return nil
+}
+
+// scriptURLPath resolves a script against the source directory and returns its
+// absolute path along with the URL path its environment is keyed by
+func (m *Middleware) scriptURLPath(scriptPath string) (string, string, error) {
+	scriptPath = m.absScriptPath(scriptPath)
+
+	// Environments mirror the source directory, so the script has to live inside it
+	relPath, err := filepath.Rel(m.sourceDir, scriptPath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", "", fmt.Errorf("script %s is outside the source directory", scriptPath)
+	}
+
+	return scriptPath, "/" + filepath.ToSlash(relPath), nil
+}
+
+// ExecuteTo runs a PHP script outside of any HTTP request and writes its output to w,
+// e.g. to generate reports or static pages offline. The script sees a GET request for
+// "/". Data is passed to PHP like render data. An error is returned if the script
+// can't be run or responds with an error status.
+func (m *Middleware) ExecuteTo(ctx context.Context, scriptPath string, data map[string]interface{}, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	output := &writerResponse{header: make(http.Header), writer: w}
+	if err := m.executeScript(req, scriptPath, data, output); err != nil {
+		return err
+	}
+
+	if output.status >= http.StatusBadRequest {
+		return fmt.Errorf("script %s responded with status %d: %s", scriptPath, output.status, strings.TrimSpace(output.errorBody.String()))
+	}
+	return nil
+}
+
+// Execute runs a single PHP script against req and returns the recorded response,
+// so tests can assert on status, headers and body without starting a server.
+// The script path is relative to the source directory. PHP is initialized on the
+// first call and reused afterwards. An error is only returned if the script can't
+// be run at all; PHP error statuses are left in the recorder.
+func (m *Middleware) Execute(scriptPath string, req *http.Request) (*httptest.ResponseRecorder, error) {
+	recorder := httptest.NewRecorder()
+	if err := m.executeScript(req, scriptPath, nil, recorder); err != nil {
+		return nil, err
+	}
+	return recorder, nil
+}
+
+// writerResponse is a ResponseWriter that streams successful output to an io.Writer
+// and holds back error responses so they can be returned as errors
+type writerResponse struct {
+	header    http.Header
+	status    int
+	writer    io.Writer
+	errorBody bytes.Buffer
+}
+
+// Header returns the response headers, which are discarded
+func (o *writerResponse) Header() http.Header {
+	return o.header
+}
+
+// WriteHeader records the status code (only the first call counts)
+func (o *writerResponse) WriteHeader(status int) {
+	if o.status == 0 {
+		o.status = status
+	}
+}
+
+// Write forwards output to the writer, or captures it for an error status
+func (o *writerResponse) Write(p []byte) (int, error) {
+	if o.status == 0 {
+		o.status = http.StatusOK
+	}
+	if o.status >= http.StatusBadRequest {
+		return o.errorBody.Write(p)
+	}
+	return o.writer.Write(p)
+}
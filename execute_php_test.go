@@ -0,0 +1,43 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExecuteToWritesOutput(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"report.php": `<?php echo "Report for " . frango_var('month') . ": " . count(frango_var('rows', [])) . " rows";`,
+		"failed.php": `<?php http_response_code(503); echo "database down";`,
+	}, quietLogger())
+	defer cleanup()
+
+	var buf bytes.Buffer
+	err := m.ExecuteTo(context.Background(), "report.php", map[string]interface{}{
+		"month": "March",
+		"rows":  []int{1, 2, 3},
+	}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "Report for March: 3 rows" {
+		t.Errorf("output = %q, want the rendered report", got)
+	}
+
+	buf.Reset()
+	err = m.ExecuteTo(context.Background(), "failed.php", nil, &buf)
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "database down") {
+		t.Errorf("error = %v, want the status and body of the failed script", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("an error response was written to the buffer: %q", buf.String())
+	}
+
+	if err := m.ExecuteTo(context.Background(), "../outside.php", nil, &buf); err == nil {
+		t.Error("ExecuteTo ran a script outside the source directory")
+	}
+}
@@ -0,0 +1,39 @@
+package frango
+
+import "strings"
+
+// WithPHPExtensions sets the file extensions run as PHP, e.g. ".php", ".phtml" for
+// legacy templates. Files with these extensions are executed, found without their
+// extension for clean URLs, and never served as static files. Defaults to ".php".
+func WithPHPExtensions(extensions ...string) Option {
+	return func(m *Middleware) {
+		m.phpExtensions = m.phpExtensions[:0]
+		for _, ext := range extensions {
+			ext = strings.ToLower(strings.TrimSpace(ext))
+			if ext == "" {
+				continue
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			m.phpExtensions = append(m.phpExtensions, ext)
+		}
+	}
+}
+
+// phpExtension returns the PHP extension a file name ends with, matched case-insensitively
+func (m *Middleware) phpExtension(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, ext := range m.phpExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return name[len(name)-len(ext):], true
+		}
+	}
+	return "", false
+}
+
+// isPHPFile reports whether a file name has one of the PHP extensions
+func (m *Middleware) isPHPFile(name string) bool {
+	_, ok := m.phpExtension(name)
+	return ok
+}
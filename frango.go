@@ -3,19 +3,23 @@ package frango
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/dunglas/frankenphp"
 )
 
 // Middleware is the core PHP middleware type that implements http.Handler
@@ -26,8 +30,101 @@ type Middleware struct {
 	initialized     bool
 	initLock        sync.Mutex
 	routes          map[string]string
+	prefixRoutes    map[string]bool // route keys explicitly mounted as prefixes
 	developmentMode bool
 	envCache        *EnvironmentCache
+	responseCache   *responseCache
+	phpIni          map[string]string
+
+	sourceAnnotations bool
+
+	workerPaths   []string
+	workerScripts map[string]bool
+	numWorkers    int
+	numThreads    int
+
+	variantConfigs []variantConfig
+	variants       map[string][]scriptVariant
+
+	responseStreaming bool
+	headOptimization  bool
+	accessLog         *accessLog
+	pathSuperglobals  bool
+
+	responseTransformer ResponseTransformer
+
+	rewriteRules []RewriteRule
+	rewrites     []compiledRewrite
+
+	metadataConfigs   []metadataConfig
+	metadataProviders map[string]MetadataProvider
+
+	envRetryAttempts int
+	envRetryBackoff  time.Duration
+
+	sessionStore SessionStore
+	serverTiming bool
+	staticMaxAge time.Duration
+	pathCleaning PathCleaningPolicy
+	errorHandler ErrorHandler
+
+	maxEnvVars  int
+	maxEnvBytes int
+
+	embeddedScripts map[string]string
+	embedMutex      sync.Mutex
+
+	phpInfo   *PHPInfo
+	infoMutex sync.Mutex
+
+	noSniff          bool
+	strictRenderData bool
+
+	contentTypes []string
+	maxURLLength int
+
+	events        chan FrangoEvent
+	eventsEnabled atomic.Bool
+
+	activeRequests  atomic.Int64
+	shuttingDown    atomic.Bool
+	shutdownTimeout time.Duration
+
+	directoryListing bool
+	tracer           Tracer
+	metrics          MetricsCollector
+	strictErrors     bool
+	indexFile        string
+	phpExtensions    []string
+	requestTimeout   time.Duration
+	basePath         string
+	compression      bool
+	compressionLevel int
+	readOnlySource   bool
+	requestPreparer  func(r *http.Request, env map[string]string)
+	dynamicEnv       func(r *http.Request) map[string]string
+	maxEnvironments  int
+	notFoundHandler  http.Handler
+	defaultHeaders   map[string]string
+	cors             *CORSOptions
+	blockedPaths     []string
+	strictSourceDir  bool
+	checkInterval    time.Duration
+	etags            bool
+	recoverPanics    bool
+	requestIDHeader  string
+	// documentRootStrategy picks the DOCUMENT_ROOT exposed to PHP
+	documentRootStrategy string
+	// responseCacheMaxEntries overrides the response cache's default bound
+	responseCacheMaxEntries int
+	// openBasedir confines PHP file access to openBasedirPaths
+	openBasedir     bool
+	openBasedirDirs []string
+	// userPrepend is the auto_prepend_file configured before the helper script took its place
+	userPrepend string
+
+	envPassthrough []string
+	staticEnv      map[string]string
 }
 
 // Config represents configuration options for the middleware
@@ -44,9 +141,26 @@ type Config struct {
 func New(opts ...Option) (*Middleware, error) {
 	// Default configuration
 	m := &Middleware{
-		routes:          make(map[string]string),
-		developmentMode: true,
-		logger:          log.New(os.Stdout, "[frango] ", log.LstdFlags),
+		routes:            make(map[string]string),
+		prefixRoutes:      make(map[string]bool),
+		phpIni:            make(map[string]string),
+		workerScripts:     make(map[string]bool),
+		variants:          make(map[string][]scriptVariant),
+		metadataProviders: make(map[string]MetadataProvider),
+		staticEnv:         make(map[string]string),
+		embeddedScripts:   make(map[string]string),
+		defaultHeaders:    make(map[string]string),
+		events:            make(chan FrangoEvent, eventBufferSize),
+		developmentMode:   true,
+		noSniff:           true,
+		pathSuperglobals:  true,
+		indexFile:         "index.php",
+		phpExtensions:     []string{".php"},
+		blockedPaths:      append([]string(nil), defaultBlockedPaths...),
+		metrics:           noopMetrics{},
+		staticMaxAge:      time.Hour,
+		shutdownTimeout:   defaultShutdownTimeout,
+		logger:            log.New(os.Stdout, "[frango] ", log.LstdFlags),
 	}
 
 	// Apply options
@@ -65,7 +179,11 @@ func New(opts ...Option) (*Middleware, error) {
 		}
 	} else {
 		// Resolve source directory using the path resolution function
-		absSourceDir, err = ResolveDirectory(m.sourceDir)
+		if m.strictSourceDir {
+			absSourceDir, err = resolveDirectoryStrict(m.sourceDir)
+		} else {
+			absSourceDir, err = ResolveDirectory(m.sourceDir)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error resolving source directory: %w", err)
 		}
@@ -81,8 +199,42 @@ func New(opts ...Option) (*Middleware, error) {
 	m.sourceDir = absSourceDir
 	m.tempDir = tempDir
 
+	// Resolve worker scripts against the source directory
+	if err := m.resolveWorkerScripts(); err != nil {
+		return nil, err
+	}
+
+	// Compile path rewrite rules
+	if err := m.compileRewrites(); err != nil {
+		return nil, err
+	}
+
+	// Resolve script variants against the source directory
+	if err := m.resolveVariants(); err != nil {
+		return nil, err
+	}
+
+	// Key metadata providers by absolute script path
+	m.resolveMetadataProviders()
+
+	// Strict mode needs PHP to display its errors, marked, and to never stream them
+	if err := m.applyStrictErrorIni(); err != nil {
+		return nil, err
+	}
+
+	// Streaming wins over features that need the whole response
+	m.warnStreamingConflicts()
+
+	// Bound the response cache
+	if m.responseCache != nil && m.responseCacheMaxEntries > 0 {
+		m.responseCache.maxEntries = m.responseCacheMaxEntries
+	}
+
 	// Create environment cache
 	m.envCache = NewEnvironmentCache(absSourceDir, tempDir, m.logger, m.developmentMode)
+	m.envCache.onEvent = m.emit
+	m.envCache.maxEnvironments = m.maxEnvironments
+	m.envCache.checkInterval = m.checkInterval
 
 	// Clean any stored routes that might have query strings (defensive coding)
 	for pattern, phpFile := range m.routes {
@@ -97,19 +249,33 @@ func New(opts ...Option) (*Middleware, error) {
 
 // ServeHTTP implements the http.Handler interface
 func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Reject oversized URLs before doing any work
+	if m.urlTooLong(r) {
+		http.Error(w, "URI Too Long", http.StatusRequestURITooLong)
+		return
+	}
+
 	// Initialize if needed
-	if !m.initialized {
-		m.initLock.Lock()
-		if !m.initialized { // Double-check after acquiring lock
-			if err := m.initialize(r.Context()); err != nil {
-				m.logger.Printf("Error initializing PHP environment: %v", err)
-				http.Error(w, "PHP initialization error", http.StatusInternalServerError)
-				m.initLock.Unlock()
-				return
-			}
-			m.initialized = true
-		}
-		m.initLock.Unlock()
+	if err := m.ensureInitialized(r.Context()); err != nil {
+		m.logger.Printf("Error initializing PHP environment: %v", err)
+		http.Error(w, "PHP initialization error", http.StatusInternalServerError)
+		return
+	}
+
+	// Normalize the path before anything matches on it
+	r, ok := m.cleanRequestPath(r)
+	if !ok {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	// Route relative to the mount point
+	r = m.stripBasePath(r)
+
+	// Apply rewrite rules before routing
+	r, redirected := m.rewriteRequest(w, r)
+	if redirected {
+		return
 	}
 
 	path := r.URL.Path
@@ -135,11 +301,13 @@ func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Check for .php extension version
-	if !strings.HasSuffix(path, ".php") {
-		if phpFile, found := m.routes[path+".php"]; found {
-			m.servePHPFile(path+".php", phpFile, w, r)
-			return
+	// Check for a version with a PHP extension
+	if !m.isPHPFile(path) {
+		for _, ext := range m.phpExtensions {
+			if phpFile, found := m.routes[path+ext]; found {
+				m.servePHPFile(path+ext, phpFile, w, r)
+				return
+			}
 		}
 	}
 
@@ -149,36 +317,51 @@ func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			m.servePHPFile("/", phpFile, w, r)
 			return
 		}
-		indexPath := filepath.Join(m.sourceDir, "index.php")
+		indexPath := filepath.Join(m.sourceDir, m.indexFile)
 		if _, err := os.Stat(indexPath); err == nil {
 			m.servePHPFile("/", indexPath, w, r)
 			return
 		}
 	}
 
+	// Never expose sensitive files from the source directory
+	if m.isBlockedPath(path) {
+		m.logger.Printf("Blocked request for %s", path)
+		http.NotFound(w, r)
+		return
+	}
+
 	// Check for direct PHP file access
 	phpPath := filepath.Join(m.sourceDir, strings.TrimPrefix(path, "/"))
-	if _, err := os.Stat(phpPath); err == nil {
-		if strings.HasSuffix(phpPath, ".php") {
+	if !m.inSourceDir(phpPath) {
+		// A raw ".." path (PathPassthrough) must not reach outside the source directory
+		m.logger.Printf("Rejected request for %s outside the source directory", path)
+		http.NotFound(w, r)
+		return
+	}
+	if info, err := os.Stat(phpPath); err == nil && !info.IsDir() {
+		if m.isPHPFile(phpPath) {
 			m.servePHPFile(path, phpPath, w, r)
 			return
 		} else {
 			// Serve static file
-			http.ServeFile(w, r, phpPath)
+			m.serveStatic(w, r, phpPath)
 			return
 		}
 	}
 
-	// Check for PHP file with .php extension added
-	if !strings.HasSuffix(phpPath, ".php") {
-		phpPathWithExt := phpPath + ".php"
-		if _, err := os.Stat(phpPathWithExt); err == nil {
-			m.servePHPFile(path, phpPathWithExt, w, r)
-			return
+	// Check for PHP file with a PHP extension added
+	if !m.isPHPFile(phpPath) {
+		for _, ext := range m.phpExtensions {
+			phpPathWithExt := phpPath + ext
+			if _, err := os.Stat(phpPathWithExt); err == nil {
+				m.servePHPFile(path, phpPathWithExt, w, r)
+				return
+			}
 		}
 	}
 
-	// Directory check - look for index.php
+	// Directory check - look for the index file
 	dirPath := path
 	if !strings.HasSuffix(dirPath, "/") {
 		dirPath = dirPath + "/"
@@ -186,17 +369,57 @@ func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	dirPhpPath := filepath.Join(m.sourceDir, strings.TrimPrefix(dirPath, "/"))
 	if stat, err := os.Stat(dirPhpPath); err == nil && stat.IsDir() {
-		indexPath := filepath.Join(dirPhpPath, "index.php")
+		indexPath := filepath.Join(dirPhpPath, m.indexFile)
 		if _, err := os.Stat(indexPath); err == nil {
 			m.servePHPFile(dirPath, indexPath, w, r)
 			return
 		}
+
+		// List the directory's routes when it has no index
+		if m.directoryListing {
+			m.serveDirectoryListing(w, dirPath, dirPhpPath)
+			return
+		}
+	}
+
+	// Hand paths under a script mounted at a prefix to it, with the rest as PATH_INFO
+	if pattern, phpFile, pathInfo, found := m.matchPrefixRoute(r.Method, path); found {
+		m.servePHPFile(pattern, phpFile, w, withPathInfo(r, pathInfo))
+		return
 	}
 
 	// Not found
+	if m.notFoundHandler != nil {
+		m.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
 	http.NotFound(w, r)
 }
 
+// SetNotFoundHandler sets the handler for requests that match no PHP script or file,
+// e.g. to render a branded 404 page or delegate to another router. Defaults to http.NotFound.
+func (m *Middleware) SetNotFoundHandler(handler http.Handler) {
+	m.notFoundHandler = handler
+}
+
+// ensureInitialized initializes PHP on first use
+func (m *Middleware) ensureInitialized(ctx context.Context) error {
+	if m.initialized {
+		return nil
+	}
+
+	m.initLock.Lock()
+	defer m.initLock.Unlock()
+
+	if !m.initialized { // Double-check after acquiring lock
+		if err := m.initialize(ctx); err != nil {
+			return err
+		}
+		m.initialized = true
+	}
+	return nil
+}
+
 // initialize initializes the PHP environment with context
 func (m *Middleware) initialize(ctx context.Context) error {
 	// Create a background context if nil is provided
@@ -211,26 +434,61 @@ func (m *Middleware) initialize(ctx context.Context) error {
 	default:
 	}
 
-	// Initialize FrankenPHP
-	if err := frankenphp.Init(); err != nil {
+	// Prepend the helper functions to every script
+	if err := m.writeUtilityScript(); err != nil {
+		return err
+	}
+
+	// Write ini directives before PHP starts, it only reads them once
+	if err := m.writePHPIni(); err != nil {
+		return err
+	}
+
+	// Initialize FrankenPHP, or join the runtime another instance started
+	first, err := acquirePHP(m.workerPools(), m.numThreads, m.runtimeIni())
+	if err != nil {
 		return fmt.Errorf("error initializing FrankenPHP: %w", err)
 	}
+	if !first {
+		m.logger.Printf("Sharing FrankenPHP with another frango instance")
+	}
 
 	return nil
 }
 
 // Shutdown cleans up resources
 func (m *Middleware) Shutdown() {
+	// Let in-flight PHP requests finish before their files go away
+	m.drainRequests()
+
+	// Clean up all environments
+	m.envCache.Cleanup()
+
+	// FrankenPHP is shared by every instance and only stops with the last one,
+	// which also removes the temp directory
 	if m.initialized {
-		frankenphp.Shutdown()
+		releasePHP(m.tempDir)
 		m.initialized = false
+		return
 	}
 
-	// Clean up all environments
-	m.envCache.Cleanup()
+	// Remove the temp directory, unless PHP still needs it for another instance
+	if !retainedByPHP(m.tempDir) {
+		os.RemoveAll(m.tempDir)
+	}
+}
+
+// ClearCache discards every cached PHP environment and removes its temp directory, so
+// the next request to each endpoint rebuilds from the source directory. It also empties
+// the response cache. Requests still running finish against their old environment.
+func (m *Middleware) ClearCache() {
+	count := m.envCache.Clear()
 
-	// Remove the temp directory
-	os.RemoveAll(m.tempDir)
+	if m.responseCache != nil {
+		m.responseCache.clear()
+	}
+
+	m.logger.Printf("Cleared %d cached environments", count)
 }
 
 // Handle registers a PHP file to serve at a specific path
@@ -245,13 +503,28 @@ func (m *Middleware) Handle(pattern string, phpFile string) {
 	m.HandlePHP(pattern, phpFile)
 }
 
-// HandlePHP maps a URL pattern to a PHP file
+// HandlePHP maps a URL pattern to a PHP file. A pattern ending in a slash also
+// mounts the script at that prefix, receiving the rest of the path as PATH_INFO.
 func (m *Middleware) HandlePHP(pattern string, phpFile string) {
 	// Ensure URL path starts with a slash
 	if !strings.HasPrefix(pattern, "/") {
 		pattern = "/" + pattern
 	}
 
+	m.handlePHP(pattern, phpFile)
+
+	if pattern != "/" && strings.HasSuffix(pattern, "/") {
+		m.prefixRoutes[pattern] = true
+	}
+}
+
+// handlePHP maps a URL pattern to a PHP file without mounting it as a prefix
+func (m *Middleware) handlePHP(pattern string, phpFile string) {
+	// Ensure URL path starts with a slash
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+
 	// Strip any query string from the PHP file path
 	if queryIndex := strings.Index(phpFile, "?"); queryIndex != -1 {
 		phpFile = phpFile[:queryIndex]
@@ -276,6 +549,13 @@ func (m *Middleware) HandlePHP(pattern string, phpFile string) {
 
 // HandleDir registers all PHP files in a directory under a URL prefix
 func (m *Middleware) HandleDir(prefix string, dirPath string) error {
+	_, err := m.handleDir(prefix, dirPath)
+	return err
+}
+
+// handleDir registers all PHP files in a directory under a URL prefix and returns
+// the URL patterns it registered
+func (m *Middleware) handleDir(prefix string, dirPath string) ([]string, error) {
 	// Ensure URL prefix starts with a slash
 	if !strings.HasPrefix(prefix, "/") {
 		prefix = "/" + prefix
@@ -294,27 +574,37 @@ func (m *Middleware) HandleDir(prefix string, dirPath string) error {
 	// Check if directory exists
 	info, err := os.Stat(dirPath)
 	if err != nil {
-		return fmt.Errorf("error accessing directory %s: %w", dirPath, err)
+		return nil, fmt.Errorf("error accessing directory %s: %w", dirPath, err)
 	}
 
 	if !info.IsDir() {
-		return fmt.Errorf("%s is not a directory", dirPath)
+		return nil, fmt.Errorf("%s is not a directory", dirPath)
 	}
 
 	// Walk directory and register all PHP files
 	count := 0
+	var patterns []string
 	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// Blocked files must not become routes, explicit routes are served before the block check
+		if srcRel, err := filepath.Rel(m.sourceDir, path); err == nil && path != dirPath && m.isBlockedPath("/"+filepath.ToSlash(srcRel)) {
+			m.logger.Printf("Not registering blocked path %s", path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Skip directories
 		if info.IsDir() {
 			return nil
 		}
 
 		// Only process PHP files
-		if strings.HasSuffix(strings.ToLower(info.Name()), ".php") {
+		if ext, isPHP := m.phpExtension(info.Name()); isPHP {
 			// Calculate URL path
 			relPath, err := filepath.Rel(dirPath, path)
 			if err != nil {
@@ -331,23 +621,27 @@ func (m *Middleware) HandleDir(prefix string, dirPath string) error {
 			}
 			urlPath += relPath
 
-			// Register the path with .php extension
+			// Register the path with its extension
 			m.HandlePHP(urlPath, path)
+			patterns = append(patterns, urlPath)
 
-			// Also register without .php extension for clean URLs
-			if strings.HasSuffix(urlPath, ".php") {
-				cleanPath := strings.TrimSuffix(urlPath, ".php")
+			// Also register without the extension for clean URLs
+			if strings.HasSuffix(urlPath, ext) {
+				cleanPath := strings.TrimSuffix(urlPath, ext)
 				m.HandlePHP(cleanPath, path)
+				patterns = append(patterns, cleanPath)
 
-				// For index.php files, also register the directory path
-				if filepath.Base(relPath) == "index.php" {
+				// For index files, also register the directory path
+				if filepath.Base(relPath) == m.indexFile {
 					dirPath := filepath.Dir(urlPath)
 					if dirPath != "/" {
 						// Ensure the directory path ends with a slash
 						if !strings.HasSuffix(dirPath, "/") {
 							dirPath += "/"
 						}
-						m.HandlePHP(dirPath, path)
+						// Only the directory itself, its subpaths aren't the index's
+						m.handlePHP(dirPath, path)
+						patterns = append(patterns, dirPath)
 					}
 				}
 			}
@@ -359,11 +653,11 @@ func (m *Middleware) HandleDir(prefix string, dirPath string) error {
 	})
 
 	if err != nil {
-		return fmt.Errorf("error walking directory: %w", err)
+		return patterns, fmt.Errorf("error walking directory: %w", err)
 	}
 
 	m.logger.Printf("Registered %d PHP files from directory %s under %s", count, dirPath, prefix)
-	return nil
+	return patterns, nil
 }
 
 // AddFromEmbed adds a PHP file from an embed.FS
@@ -433,6 +727,9 @@ func (m *Middleware) handleMethod(pattern string, phpFilePath string) {
 	// Register the endpoint with a special internal key format
 	internalKey := method + ":" + path
 	m.routes[internalKey] = phpFilePath
+	if path != "/" && strings.HasSuffix(path, "/") {
+		m.prefixRoutes[internalKey] = true
+	}
 
 	m.logger.Printf("Registered %s endpoint: %s -> %s", method, path, phpFilePath)
 }
@@ -453,6 +750,24 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 
 // shouldHandlePHP determines if we should handle this request as PHP
 func (m *Middleware) shouldHandlePHP(r *http.Request) bool {
+	// Rejected URLs and paths are answered by ServeHTTP
+	if m.urlTooLong(r) {
+		return true
+	}
+	r, ok := m.cleanRequestPath(r)
+	if !ok {
+		return true
+	}
+	r = m.stripBasePath(r)
+
+	// Redirects are always ours, internal rewrites route on the target path
+	if rule, target := m.matchRewrite(r.URL.Path); rule != nil {
+		if rule.Redirect != 0 {
+			return true
+		}
+		r = rewrittenRequest(r, target)
+	}
+
 	path := r.URL.Path
 
 	// Check for method-specific routes first
@@ -473,36 +788,44 @@ func (m *Middleware) shouldHandlePHP(r *http.Request) bool {
 		}
 	}
 
-	// Also check for path with .php extension
-	if !strings.HasSuffix(path, ".php") {
-		if _, exists := m.routes[path+".php"]; exists {
-			return true
+	// Also check for path with a PHP extension
+	if !m.isPHPFile(path) {
+		for _, ext := range m.phpExtensions {
+			if _, exists := m.routes[path+ext]; exists {
+				return true
+			}
 		}
 	}
 
+	// Blocked files are answered with 404, not handed to the next handler
+	if m.isBlockedPath(path) {
+		return true
+	}
+
 	// Check for explicit PHP files
 	phpPath := filepath.Join(m.sourceDir, strings.TrimPrefix(path, "/"))
-	if _, err := os.Stat(phpPath); err == nil && strings.HasSuffix(phpPath, ".php") {
+	if _, err := os.Stat(phpPath); err == nil && m.isPHPFile(phpPath) {
 		return true
 	}
 
-	// If path doesn't end with .php, check if a .php version exists
-	if !strings.HasSuffix(phpPath, ".php") {
-		phpPathWithExt := phpPath + ".php"
-		if _, err := os.Stat(phpPathWithExt); err == nil {
-			return true
+	// If path has no PHP extension, check if a version with one exists
+	if !m.isPHPFile(phpPath) {
+		for _, ext := range m.phpExtensions {
+			if _, err := os.Stat(phpPath + ext); err == nil {
+				return true
+			}
 		}
 	}
 
-	// Check for directory index.php
+	// Check for the directory index file
 	if !strings.HasSuffix(path, "/") {
 		path = path + "/"
 	}
 
 	dirPath := filepath.Join(m.sourceDir, strings.TrimPrefix(path, "/"))
 	if stat, err := os.Stat(dirPath); err == nil && stat.IsDir() {
-		indexPath := filepath.Join(dirPath, "index.php")
-		if _, err := os.Stat(indexPath); err == nil {
+		indexPath := filepath.Join(dirPath, m.indexFile)
+		if _, err := os.Stat(indexPath); err == nil || m.directoryListing {
 			return true
 		}
 	}
@@ -512,12 +835,17 @@ func (m *Middleware) shouldHandlePHP(r *http.Request) bool {
 		if _, exists := m.routes["/"]; exists {
 			return true
 		}
-		indexPath := filepath.Join(m.sourceDir, "index.php")
+		indexPath := filepath.Join(m.sourceDir, m.indexFile)
 		if _, err := os.Stat(indexPath); err == nil {
 			return true
 		}
 	}
 
+	// Check for scripts mounted at a prefix of the path
+	if _, _, _, found := m.matchPrefixRoute(r.Method, r.URL.Path); found {
+		return true
+	}
+
 	return false
 }
 
@@ -566,8 +894,72 @@ func (m *Middleware) HandleRender(pattern string, phpFile string, renderFn Rende
 	m.logger.Printf("Registered render endpoint: %s -> %s", pattern, phpFilePath)
 }
 
-// servePHPFile serves a PHP file, checking if it needs special render handling
+// servePHPFile serves a PHP file, going through the response cache when enabled
 func (m *Middleware) servePHPFile(urlPath string, sourcePath string, w http.ResponseWriter, r *http.Request) {
+	// Expose the matched route to render functions and downstream code
+	r = m.withRoute(r, urlPath, sourcePath)
+
+	// Tag the request with an ID shared by Go and PHP logs
+	r = m.assignRequestID(w, r)
+
+	// Write the access log entry once the response is done
+	w, logAccess := m.logAccess(w, r, sourcePath)
+	defer logAccess()
+
+	// Report the request to event listeners
+	if m.eventsEnabled.Load() {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		w = recorder
+		m.emit(FrangoEvent{Type: EventRequestStarted, Path: urlPath, ScriptPath: sourcePath})
+		defer func() {
+			m.emit(FrangoEvent{
+				Type:       EventRequestCompleted,
+				Path:       urlPath,
+				ScriptPath: sourcePath,
+				Status:     recorder.statusCode(),
+				Duration:   time.Since(started),
+			})
+		}()
+	}
+
+	// Answer with a 500 if Go code such as a render function panics
+	defer m.recoverPanic(w, sourcePath)
+
+	// Answer CORS preflights without running PHP
+	if m.handleCORS(w, r) {
+		return
+	}
+
+	// Answer HEAD and conditional requests from Go-side metadata when possible
+	if m.serveFromMetadata(w, r, sourcePath) {
+		return
+	}
+
+	// HEAD gets GET's headers and Content-Length, but the body is never sent
+	if r.Method == http.MethodHead {
+		m.serveHead(w, r, func(w http.ResponseWriter) {
+			m.handleWithTimeout(urlPath, sourcePath, w, r)
+		})
+		return
+	}
+
+	// Streamed responses must reach the client unbuffered
+	if m.responseCache != nil && r.Method == http.MethodGet && !m.responseStreaming {
+		m.serveCached(w, r, func(w http.ResponseWriter) {
+			m.handleWithTimeout(urlPath, sourcePath, w, r)
+		})
+		return
+	}
+
+	m.handleWithTimeout(urlPath, sourcePath, w, r)
+}
+
+// handlePHPFile executes a PHP file, checking if it needs special render handling
+func (m *Middleware) handlePHPFile(urlPath string, sourcePath string, w http.ResponseWriter, r *http.Request) {
+	// Swap in an alternate representation if the request selects one
+	sourcePath = m.selectVariant(w, r, sourcePath)
+
 	// Check if this is a render path with a render function
 	renderHandlersMutex.RLock()
 	renderFn, isRenderPath := renderHandlers[urlPath]
@@ -582,33 +974,58 @@ func (m *Middleware) servePHPFile(urlPath string, sourcePath string, w http.Resp
 
 		// Call the render function to get data
 		data := renderFn(w, r)
+		m.addPushHints(w, data)
 
-		// Add a render flag
-		pathParams["RENDER"] = "true"
+		var err error
+		if pathParams, err = m.renderParams(data); err != nil {
+			m.logger.Printf("Error preparing render data for %s: %v", urlPath, err)
+			http.Error(w, "Render data error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 
-		// Debug the render data
-		m.logger.Printf("Render data keys: %v", getMapKeys(data))
+	// Serve the PHP file with the appropriate parameters
+	m.servePHPFileWithPathParams(urlPath, sourcePath, pathParams, w, r)
+}
 
-		// Convert the data to environment variables
-		for key, value := range data {
-			jsonData, err := json.Marshal(value)
-			if err != nil {
-				m.logger.Printf("Error marshaling data for %s: %v", key, err)
-				continue
-			}
+// renderParams converts render data into the path parameters passed to PHP.
+// Values that can't be marshaled to JSON are dropped, or reported as an error with
+// WithStrictRenderData in development mode.
+func (m *Middleware) renderParams(data map[string]interface{}) (map[string]string, error) {
+	pathParams := make(map[string]string)
 
-			// Log the JSON data for debugging
-			m.logger.Printf("Render data for %s: %s", key, string(jsonData))
+	// Add a render flag
+	pathParams["RENDER"] = "true"
 
-			// Add variables with different prefixes for compatibility
-			frVarKey := "frango_VAR_" + key
-			pathParams[frVarKey] = string(jsonData)
-			pathParams["PATH_PARAM_"+strings.ToUpper(key)] = string(jsonData)
+	// Debug the render data
+	m.logger.Printf("Render data keys: %v", getMapKeys(data))
+
+	// Convert the data to environment variables
+	for key, value := range data {
+		// Push hints are sent as headers, not passed to the script
+		if key == PushKey {
+			continue
 		}
+
+		jsonData, err := json.Marshal(value)
+		if err != nil {
+			if m.strictRenderData && m.developmentMode {
+				return nil, fmt.Errorf("render data key %q is not JSON-serializable: %w", key, err)
+			}
+			m.logger.Printf("WARNING: Dropping render data key %s, it can't be marshaled to JSON: %v", key, err)
+			continue
+		}
+
+		// Log the JSON data for debugging
+		m.logger.Printf("Render data for %s: %s", key, string(jsonData))
+
+		// Add variables with different prefixes for compatibility
+		frVarKey := "frango_VAR_" + key
+		pathParams[frVarKey] = string(jsonData)
+		pathParams["PATH_PARAM_"+strings.ToUpper(key)] = string(jsonData)
 	}
 
-	// Serve the PHP file with the appropriate parameters
-	m.servePHPFileWithPathParams(urlPath, sourcePath, pathParams, w, r)
+	return pathParams, nil
 }
 
 // getMapKeys is a helper function to get the keys of a map for logging
@@ -622,6 +1039,25 @@ func getMapKeys(m map[string]interface{}) []string {
 
 // servePHPFileWithPathParams serves a PHP file with path parameters
 func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath string, pathParams map[string]string, w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+
+	// Report the request's status and duration to the metrics collector
+	w, observe := m.observeRequest(w, sourcePath, started)
+	defer observe()
+
+	// Add path values matched by a Go ServeMux pattern, without overriding explicit parameters
+	for name, value := range patternPathValues(r) {
+		if _, exists := pathParams[name]; !exists {
+			pathParams[name] = value
+		}
+	}
+
+	// Track the request so Shutdown can wait for it
+	if !m.beginRequest(w) {
+		return
+	}
+	defer m.endRequest()
+
 	// Strip any query string from the source path - put this early
 	originalSourcePath := sourcePath
 	if queryIndex := strings.Index(sourcePath, "?"); queryIndex != -1 {
@@ -630,14 +1066,6 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 		m.logger.Printf("Stripped to: %s", sourcePath)
 	}
 
-	// Get or create environment for this endpoint
-	env, err := m.envCache.GetEnvironment(urlPath, sourcePath)
-	if err != nil {
-		m.logger.Printf("Error setting up environment for %s: %v", urlPath, err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
-		return
-	}
-
 	// Calculate the path to the original PHP file relative to the source directory
 	relPath, err := filepath.Rel(m.sourceDir, sourcePath)
 	if err != nil {
@@ -646,57 +1074,22 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 		return
 	}
 
-	// Calculate the path to the PHP file in the environment
-	phpFilePath := filepath.Join(env.TempPath, relPath)
-
-	// Debug the paths
 	m.logger.Printf("Original sourcePath: %s", originalSourcePath)
-	m.logger.Printf("Cleaned sourcePath: %s", sourcePath)
-	m.logger.Printf("relPath: %s", relPath)
-	m.logger.Printf("phpFilePath to look for: %s", phpFilePath)
-
-	// Ensure this is actually pointing to a file, not a directory
-	fileInfo, err := os.Stat(phpFilePath)
-	if err != nil {
-		// If file doesn't exist, log and try to rebuild
-		m.logger.Printf("Error accessing PHP file %s: %v", phpFilePath, err)
 
-		// If the file doesn't exist but the environment does, try to rebuild it
-		if os.IsNotExist(err) {
-			m.logger.Printf("Trying to rebuild environment for %s", urlPath)
-			if err := m.envCache.mirrorFilesToEnvironment(env); err != nil {
-				m.logger.Printf("Error rebuilding environment: %v", err)
-				http.Error(w, "Server error", http.StatusInternalServerError)
-				return
-			}
-
-			// Check again after rebuilding
-			fileInfo, err = os.Stat(phpFilePath)
-			if err != nil {
-				m.logger.Printf("File still not found after rebuilding: %s", phpFilePath)
-				http.NotFound(w, r)
-				return
-			}
-		} else {
-			http.NotFound(w, r)
-			return
-		}
-	}
-
-	// Double check we're not trying to execute a directory
-	if fileInfo.IsDir() {
-		m.logger.Printf("ERROR: Path is a directory, not a PHP file: %s", phpFilePath)
-
-		// Try appending index.php if it's a directory
-		indexPath := filepath.Join(phpFilePath, "index.php")
-		if _, err := os.Stat(indexPath); err == nil {
-			m.logger.Printf("Found index.php in directory, using: %s", indexPath)
-			phpFilePath = indexPath
-		} else {
-			m.logger.Printf("No index.php found in directory: %s", phpFilePath)
-			http.Error(w, "Server error - trying to execute directory as PHP", http.StatusInternalServerError)
+	// Workers are booted from their source path, so they run in place instead of
+	// from a mirrored environment, as does everything in read-only mode
+	phpFilePath, envID := sourcePath, "worker"
+	if m.readOnlySource && !m.workerScripts[sourcePath] {
+		envID = "source"
+	} else if !m.workerScripts[sourcePath] {
+		var release func()
+		var ok bool
+		phpFilePath, envID, release, ok = m.environmentScriptPath(urlPath, sourcePath, relPath, w, r)
+		if !ok {
 			return
 		}
+		// Keep the environment from being rebuilt while PHP reads from it
+		defer release()
 	}
 
 	// *** CRITICAL: PROPERLY SETUP FRANKENPHP REQUEST ***
@@ -711,6 +1104,9 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 	// Calculate the script name (basename of the PHP file)
 	scriptName := "/" + filepath.Base(phpFilePath)
 
+	// The DOCUMENT_ROOT scripts see, which may differ from the one used to locate them
+	phpDocumentRoot := m.phpDocumentRoot(phpFilePath, relPath)
+
 	m.logger.Printf("Running PHP with DocumentRoot=%s, ScriptName=%s, URL=%s", documentRoot, scriptName, r.URL.String())
 
 	// Setup environment variables
@@ -718,7 +1114,7 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 		// DO NOT set SCRIPT_FILENAME - FrankenPHP does this automatically
 		"SCRIPT_NAME":    scriptName,
 		"PHP_SELF":       scriptName,
-		"DOCUMENT_ROOT":  documentRoot,
+		"DOCUMENT_ROOT":  phpDocumentRoot,
 		"REQUEST_URI":    r.URL.RequestURI(), // This includes query string
 		"REQUEST_METHOD": r.Method,
 		"QUERY_STRING":   r.URL.RawQuery,
@@ -730,11 +1126,17 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 		"DEBUG_PHP_FILE_PATH": phpFilePath,
 		"DEBUG_URL_PATH":      urlPath,
 		"DEBUG_SOURCE_PATH":   sourcePath,
-		"DEBUG_ENV_ID":        env.ID,
+		"DEBUG_ENV_ID":        envID,
 		"DEBUG_QUERY_STRING":  r.URL.RawQuery,
 		"DEBUG_REQUEST_URI":   r.URL.RequestURI(),
 	}
 
+	// Expose the path beyond the script to front controllers and prefix mounts
+	addPathInfoEnv(r, phpEnv)
+
+	// Show PHP the URL it was requested under, including the mount prefix
+	m.addBasePathEnv(phpEnv)
+
 	// Add path parameters to environment
 	if len(pathParams) > 0 {
 		// Create a JSON string with all path parameters
@@ -765,8 +1167,42 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 			// Add as direct environment variable for easier access
 			phpEnv["QUERY_PARAM_"+strings.ToUpper(key)] = values[0]
 		}
+		if len(values) > 1 {
+			// Repeated keys (?tag=a&tag=b) also get every value as a JSON array
+			valuesJSON, _ := json.Marshal(values)
+			phpEnv["QUERY_PARAM_ARRAY_"+strings.ToUpper(key)] = string(valuesJSON)
+		}
+	}
+
+	// Add configured static and passthrough variables
+	m.addConfiguredEnv(phpEnv)
+
+	// Let PHP limit itself to the time left before the request deadline
+	m.addDeadlineEnv(r, phpEnv)
+
+	// Let PHP log the same request ID as Go
+	if id := requestID(r); id != "" {
+		addRequestIDEnv(phpEnv, id)
+	}
+
+	// Let frango_include() name partials relative to the directory the script runs from
+	if m.developmentMode && m.sourceAnnotations {
+		phpEnv["frango_ANNOTATE"] = strings.TrimSuffix(phpFilePath, relPath)
 	}
 
+	// Tell the helper script to leave the superglobals out
+	if !m.pathSuperglobals {
+		phpEnv["frango_NO_SUPERGLOBALS"] = "1"
+	}
+
+	// Pass the content type negotiated from the Accept header
+	m.addNegotiatedEnv(w, r, phpEnv)
+
+	// Trace the PHP request and hand the trace context to PHP
+	r, w, endTrace := m.startTrace(w, r, sourcePath, phpEnv)
+	var phpErr error
+	defer func() { endTrace(phpErr) }()
+
 	// Add caching configuration
 	if !m.developmentMode {
 		phpEnv["PHP_PRODUCTION"] = "1"
@@ -776,6 +1212,21 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 		phpEnv["PHP_OPCACHE_ENABLE"] = "0"
 	}
 
+	// Load the session from the Go store and journal changes made by PHP
+	var sessionJournal string
+	if m.sessionStore != nil {
+		if sessionJournal, err = m.prepareSession(r, phpEnv); err != nil {
+			m.logger.Printf("Error preparing session: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			if err := m.commitSession(sessionJournal); err != nil {
+				m.logger.Printf("Error saving session: %v", err)
+			}
+		}()
+	}
+
 	// Clone the request and set the URL path to the script name
 	// This ensures FrankenPHP looks for the right file
 	reqClone := r.Clone(r.Context())
@@ -789,24 +1240,191 @@ func (m *Middleware) servePHPFileWithPathParams(urlPath string, sourcePath strin
 		}
 	}
 
+	// Report how long frango took to prepare the request
+	m.addServerTiming(w, phpEnv, started)
+
+	// Merge request-scoped variables (user ID, tenant, feature flags) into $_SERVER
+	if m.dynamicEnv != nil {
+		for key, value := range m.dynamicEnv(r) {
+			phpEnv[key] = value
+		}
+	}
+
+	// Keep the environment within the configured limits
+	m.limitEnv(phpEnv)
+
+	// Give the caller a last chance to adjust the request environment
+	if m.requestPreparer != nil {
+		m.requestPreparer(reqClone, phpEnv)
+	}
+
 	// Create FrankenPHP request using the correct document root
-	req, err := frankenphp.NewRequestWithContext(
-		reqClone,
-		frankenphp.WithRequestDocumentRoot(documentRoot, false), // Document root is the environment directory
-		frankenphp.WithRequestEnv(phpEnv),                       // Environment includes SCRIPT_FILENAME
-	)
+	req, err := newPHPRequest(reqClone, documentRoot, phpEnv)
 	if err != nil {
-		m.logger.Printf("Error creating PHP request: %v", err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
+		phpErr = err
+		m.phpError(w, r, sourcePath, err)
 		return
 	}
 
+	// Compress the output, annotations included, when the client accepts it
+	if compressor := m.newCompressWriter(w, r); compressor != nil {
+		defer compressor.close()
+		w = compressor
+	}
+
+	// Annotate output with the producing script in development mode
+	var annotator *annotatingWriter
+	if m.developmentMode && m.sourceAnnotations {
+		annotator = newAnnotatingWriter(w, r, filepath.ToSlash(relPath))
+		w = annotator
+	}
+
+	// Add default headers and stop browsers from MIME-sniffing PHP output
+	if m.noSniff || len(m.defaultHeaders) > 0 {
+		w = &defaultHeadersWriter{ResponseWriter: w, noSniff: m.noSniff, defaults: m.defaultHeaders}
+	}
+
+	// Keep proxies from buffering flushed output
+	m.prepareStreaming(w)
+
 	// Execute PHP
-	if err := frankenphp.ServeHTTP(w, req); err != nil {
-		m.logger.Printf("Error executing PHP: %v", err)
-		http.Error(w, "PHP execution error: "+err.Error(), http.StatusInternalServerError)
+	// In strict mode, or to transform or tag it, hold the output back until PHP is done,
+	// unless it must stream
+	var output http.ResponseWriter = w
+	var buffered *bufferedResponse
+	if !m.responseStreaming && (m.strictErrors || m.responseTransformer != nil || m.etags) {
+		buffered = newBufferedResponse()
+		output = buffered
+	}
+
+	if err := servePHPRequest(output, req); err != nil {
+		phpErr = err
+		m.phpError(w, r, sourcePath, err)
 		return
 	}
+
+	if buffered != nil {
+		if m.strictErrors {
+			if scriptErr := findScriptError(buffered.body.Bytes()); scriptErr != nil {
+				phpErr = scriptErr
+				m.phpError(w, r, sourcePath, scriptErr)
+				return
+			}
+		}
+		if m.responseTransformer != nil {
+			m.transformResponse(buffered)
+		}
+		if m.etags && m.applyETag(r, buffered) {
+			buffered.status = http.StatusNotModified
+			buffered.body.Reset()
+			buffered.Header().Del("Content-Length")
+		}
+		buffered.writeTo(w)
+	}
+
+	if annotator != nil {
+		annotator.finish()
+	}
+}
+
+// environmentScriptPath resolves the script's path inside its environment, rebuilding
+// the environment if the file is missing. On success the environment is read-locked
+// so it can't be rebuilt mid-request, and the returned function releases it. It writes
+// the error response and returns false when the script can't be served.
+func (m *Middleware) environmentScriptPath(urlPath string, sourcePath string, relPath string, w http.ResponseWriter, r *http.Request) (string, string, func(), bool) {
+	// In production nothing changes on disk, so reuse a previously validated path
+	if !m.developmentMode {
+		if resolved, found := m.envCache.resolvedScript(urlPath, sourcePath); found {
+			m.envCache.refreshIfDue(resolved.env)
+			resolved.env.mutex.RLock()
+			if !resolved.env.discarded {
+				resolved.env.touch()
+				return resolved.phpFilePath, resolved.env.ID, resolved.env.mutex.RUnlock, true
+			}
+			resolved.env.mutex.RUnlock()
+		}
+	}
+
+	// Get or create environment for this endpoint
+	var env *PHPEnvironment
+	err := m.withEnvRetry("set up environment for "+urlPath, func() (err error) {
+		env, err = m.envCache.GetEnvironment(urlPath, sourcePath)
+		return err
+	})
+	if err != nil {
+		m.logger.Printf("Error setting up environment for %s: %v", urlPath, err)
+		m.environmentError(w, err)
+		return "", "", nil, false
+	}
+
+	// Calculate the path to the PHP file in the environment
+	phpFilePath := filepath.Join(env.TempPath, relPath)
+
+	// Debug the paths
+	m.logger.Printf("Cleaned sourcePath: %s", sourcePath)
+	m.logger.Printf("relPath: %s", relPath)
+	m.logger.Printf("phpFilePath to look for: %s", phpFilePath)
+
+	// Ensure this is actually pointing to a file, not a directory
+	fileInfo, err := os.Stat(phpFilePath)
+	if err != nil {
+		// If file doesn't exist, log and try to rebuild
+		m.logger.Printf("Error accessing PHP file %s: %v", phpFilePath, err)
+
+		// If the file doesn't exist but the environment does, try to rebuild it
+		if os.IsNotExist(err) {
+			m.logger.Printf("Trying to rebuild environment for %s", urlPath)
+			if err := m.withEnvRetry("rebuild environment for "+urlPath, func() error {
+				env.mutex.Lock()
+				defer env.mutex.Unlock()
+				return m.envCache.mirrorFilesToEnvironment(env)
+			}); err != nil {
+				m.logger.Printf("Error rebuilding environment: %v", err)
+				m.envCache.discardEnvironment(env)
+				m.environmentError(w, err)
+				return "", "", nil, false
+			}
+
+			// Check again after rebuilding
+			fileInfo, err = os.Stat(phpFilePath)
+			if err != nil {
+				m.logger.Printf("File still not found after rebuilding: %s", phpFilePath)
+				http.NotFound(w, r)
+				return "", "", nil, false
+			}
+		} else {
+			http.NotFound(w, r)
+			return "", "", nil, false
+		}
+	}
+
+	// Double check we're not trying to execute a directory
+	if fileInfo.IsDir() {
+		m.logger.Printf("ERROR: Path is a directory, not a PHP file: %s", phpFilePath)
+
+		// Try appending the index file if it's a directory
+		indexPath := filepath.Join(phpFilePath, m.indexFile)
+		if _, err := os.Stat(indexPath); err == nil {
+			m.logger.Printf("Found %s in directory, using: %s", m.indexFile, indexPath)
+			phpFilePath = indexPath
+		} else {
+			m.logger.Printf("No %s found in directory: %s", m.indexFile, phpFilePath)
+			http.Error(w, "Server error - trying to execute directory as PHP", http.StatusInternalServerError)
+			return "", "", nil, false
+		}
+	}
+
+	if !m.developmentMode {
+		m.envCache.storeResolvedScript(urlPath, sourcePath, resolvedScript{phpFilePath, env})
+	}
+
+	env.mutex.RLock()
+	if env.discarded {
+		// Evicted between lookup and lock, so set up a fresh environment
+		env.mutex.RUnlock()
+		return m.environmentScriptPath(urlPath, sourcePath, relPath, w, r)
+	}
+	return phpFilePath, env.ID, env.mutex.RUnlock, true
 }
 
 // Option is a function that configures a Middleware
@@ -819,6 +1437,26 @@ func WithSourceDir(dir string) Option {
 	}
 }
 
+// WithProductionCheckInterval makes production mode re-sync each environment with the
+// source directory at most once per interval, when a request uses it, so files swapped
+// on disk by a deploy are served without a restart. Development mode checks on every
+// request regardless. 0 (the default) never checks in production.
+func WithProductionCheckInterval(interval time.Duration) Option {
+	return func(m *Middleware) {
+		m.checkInterval = interval
+	}
+}
+
+// WithStrictSourceDir resolves the source directory strictly: an absolute path, or a
+// path relative to the working directory, which must exist. It turns off the lookup
+// next to the caller's source file, which finds directories when running examples with
+// go run but not in a deployed binary.
+func WithStrictSourceDir(enabled bool) Option {
+	return func(m *Middleware) {
+		m.strictSourceDir = enabled
+	}
+}
+
 // WithDevelopmentMode enables immediate file change detection and disables caching
 func WithDevelopmentMode(enabled bool) Option {
 	return func(m *Middleware) {
@@ -826,6 +1464,62 @@ func WithDevelopmentMode(enabled bool) Option {
 	}
 }
 
+// WithIndexFile sets the script served for directory requests, e.g. "app.php" for
+// apps whose front controller isn't index.php. Defaults to index.php.
+func WithIndexFile(name string) Option {
+	return func(m *Middleware) {
+		if name != "" {
+			m.indexFile = name
+		}
+	}
+}
+
+// WithReadOnlyEnvironment runs scripts straight from the source directory instead of
+// mirroring it into a temporary environment per endpoint. Use it when the source
+// directory never changes while serving and is on fast local disk: startup no longer
+// copies the tree, but endpoints lose their isolation and file changes apply immediately.
+func WithReadOnlyEnvironment(enabled bool) Option {
+	return func(m *Middleware) {
+		m.readOnlySource = enabled
+	}
+}
+
+// WithRequestPreparer registers a function called right before each PHP request is
+// handed to FrankenPHP. It receives the request PHP will see and the environment
+// frango built, and can add, change or remove variables (e.g. SERVER_SOFTWARE).
+func WithRequestPreparer(prepare func(r *http.Request, env map[string]string)) Option {
+	return func(m *Middleware) {
+		m.requestPreparer = prepare
+	}
+}
+
+// WithMaxEnvironments caps how many PHP environments are cached. When a new
+// environment pushes the cache past the limit, the least recently used one is evicted
+// and its temp directory removed, once requests still running from it finish.
+func WithMaxEnvironments(n int) Option {
+	return func(m *Middleware) {
+		m.maxEnvironments = n
+	}
+}
+
+// WithDynamicEnv registers a function returning request-scoped variables (e.g. the user
+// ID set by a Go auth middleware) that are merged into the PHP environment, so they
+// show up in $_SERVER and getenv() rather than as render variables
+func WithDynamicEnv(fn func(r *http.Request) map[string]string) Option {
+	return func(m *Middleware) {
+		m.dynamicEnv = fn
+	}
+}
+
+// WithStrictRenderData makes render data that can't be marshaled to JSON (channels,
+// funcs, ...) fail the request with a 500 naming the offending key, instead of dropping
+// the key with a warning. It only applies in development mode.
+func WithStrictRenderData(enabled bool) Option {
+	return func(m *Middleware) {
+		m.strictRenderData = enabled
+	}
+}
+
 // WithLogger sets a custom logger
 func WithLogger(logger *log.Logger) Option {
 	return func(m *Middleware) {
@@ -833,6 +1527,24 @@ func WithLogger(logger *log.Logger) Option {
 	}
 }
 
+// resolveDirectoryStrict resolves a path as given, relative to the current working
+// directory unless absolute, without ResolveDirectory's fallbacks
+func resolveDirectoryStrict(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving absolute path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("directory %s not found (resolved to %s against the working directory): %w", path, absPath, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", absPath)
+	}
+	return absPath, nil
+}
+
 // ResolveDirectory resolves a directory path, supporting both absolute and relative paths.
 // It tries multiple strategies to find the directory:
 // 1. Use the path directly if it exists
@@ -905,8 +1617,23 @@ type PHPEnvironment struct {
 	TempPath string
 	// LastUpdated is when this environment was last rebuilt
 	LastUpdated time.Time
-	// mutex controls concurrent access to this environment
-	mutex sync.Mutex
+	// fileHashes maps paths relative to the source directory to the hash of the copy in TempPath
+	fileHashes map[string]string
+	// mutex is held for writing while the environment is rebuilt and for reading
+	// while requests run scripts from it
+	mutex sync.RWMutex
+	// lastUsed is when a request last used this environment, in Unix nanoseconds
+	lastUsed atomic.Int64
+	// lastChecked is when the environment was last compared with the source
+	// directory in production mode, in Unix nanoseconds
+	lastChecked atomic.Int64
+	// discarded is set, under mutex, once the environment's files have been removed
+	discarded bool
+}
+
+// touch records that a request is using the environment
+func (env *PHPEnvironment) touch() {
+	env.lastUsed.Store(time.Now().UnixNano())
 }
 
 // EnvironmentCache manages all PHP execution environments
@@ -923,6 +1650,25 @@ type EnvironmentCache struct {
 	logger *log.Logger
 	// developmentMode enables immediate detection of file changes
 	developmentMode bool
+	// resolved caches validated script paths inside environments (production mode)
+	resolved map[string]resolvedScript
+	// onEvent receives environment lifecycle events, if set
+	onEvent func(FrangoEvent)
+	// maxEnvironments caps the number of cached environments (0 for no limit)
+	maxEnvironments int
+	// checkInterval is how often production environments are re-synced with the
+	// source directory (0 to never)
+	checkInterval time.Duration
+	// removals tracks discarded environments whose files are still being removed
+	removals sync.WaitGroup
+	// fileOps creates the environment directories and files
+	fileOps envFileOps
+}
+
+// resolvedScript is a validated script location inside an environment
+type resolvedScript struct {
+	phpFilePath string
+	env         *PHPEnvironment
 }
 
 // NewEnvironmentCache creates a new environment cache
@@ -931,8 +1677,10 @@ func NewEnvironmentCache(sourceDir string, baseDir string, logger *log.Logger, d
 		sourceDir:       sourceDir,
 		baseDir:         baseDir,
 		environments:    make(map[string]*PHPEnvironment),
+		resolved:        make(map[string]resolvedScript),
 		logger:          logger,
 		developmentMode: developmentMode,
+		fileOps:         osFileOps,
 	}
 }
 
@@ -950,11 +1698,17 @@ func (c *EnvironmentCache) GetEnvironment(endpointPath string, originalPath stri
 	c.mutex.RUnlock()
 
 	if exists {
+		env.touch()
+
 		// Check if environment needs to be updated (in development mode or file changed)
 		if c.developmentMode {
 			if err := c.updateEnvironmentIfNeeded(env); err != nil {
+				// Don't keep serving from a half-rebuilt environment
+				c.discardEnvironment(env)
 				return nil, err
 			}
+		} else {
+			c.refreshIfDue(env)
 		}
 		return env, nil
 	}
@@ -966,13 +1720,43 @@ func (c *EnvironmentCache) GetEnvironment(endpointPath string, originalPath stri
 	}
 
 	// Store the environment
+	env.touch()
 	c.mutex.Lock()
 	c.environments[endpointPath] = env
 	c.mutex.Unlock()
 
+	c.evictLeastRecentlyUsed(env)
+
 	return env, nil
 }
 
+// evictLeastRecentlyUsed discards the least recently used environments, other than
+// keep, until the cache is within maxEnvironments
+func (c *EnvironmentCache) evictLeastRecentlyUsed(keep *PHPEnvironment) {
+	if c.maxEnvironments <= 0 {
+		return
+	}
+
+	for {
+		c.mutex.RLock()
+		var oldest *PHPEnvironment
+		if len(c.environments) > c.maxEnvironments {
+			for _, env := range c.environments {
+				if env != keep && (oldest == nil || env.lastUsed.Load() < oldest.lastUsed.Load()) {
+					oldest = env
+				}
+			}
+		}
+		c.mutex.RUnlock()
+
+		if oldest == nil {
+			return
+		}
+		c.logger.Printf("Evicting least recently used environment for %s", oldest.EndpointPath)
+		c.discardEnvironment(oldest)
+	}
+}
+
 // createEnvironment creates a new PHP execution environment
 func (c *EnvironmentCache) createEnvironment(endpointPath string, originalPath string) (*PHPEnvironment, error) {
 	// Triple check for query strings
@@ -997,12 +1781,10 @@ func (c *EnvironmentCache) createEnvironment(endpointPath string, originalPath s
 		}, id)
 	}
 
-	// Add a random suffix to avoid collisions
+	// Add a random suffix to avoid collisions, including with a discarded environment
+	// for the same endpoint whose files are still being removed
 	randBytes := make([]byte, 4)
-	for i := range randBytes {
-		randBytes[i] = byte(time.Now().Nanosecond() % 256)
-		time.Sleep(time.Nanosecond)
-	}
+	rand.Read(randBytes)
 	idSuffix := fmt.Sprintf("_%x", randBytes)
 	id = id + idSuffix
 
@@ -1011,7 +1793,7 @@ func (c *EnvironmentCache) createEnvironment(endpointPath string, originalPath s
 	if err := os.RemoveAll(tempPath); err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("error removing existing environment: %w", err)
 	}
-	if err := os.MkdirAll(tempPath, 0755); err != nil {
+	if err := c.fileOps.mkdirAll(tempPath, 0755); err != nil {
 		return nil, fmt.Errorf("error creating environment directory: %w", err)
 	}
 
@@ -1021,7 +1803,9 @@ func (c *EnvironmentCache) createEnvironment(endpointPath string, originalPath s
 		EndpointPath: endpointPath,
 		TempPath:     tempPath,
 		LastUpdated:  time.Now(),
+		fileHashes:   make(map[string]string),
 	}
+	env.lastChecked.Store(time.Now().UnixNano())
 
 	// Mirror all files to the environment
 	if err := c.mirrorFilesToEnvironment(env); err != nil {
@@ -1030,6 +1814,7 @@ func (c *EnvironmentCache) createEnvironment(endpointPath string, originalPath s
 	}
 
 	c.logger.Printf("Created environment for %s at %s", endpointPath, tempPath)
+	c.emit(EventEnvCreated, env)
 	return env, nil
 }
 
@@ -1044,25 +1829,78 @@ func (c *EnvironmentCache) updateEnvironmentIfNeeded(env *PHPEnvironment) error
 		return fmt.Errorf("error checking file %s: %w", env.OriginalPath, err)
 	}
 
-	// If the file has been modified since the environment was last updated, rebuild it
-	if fileInfo.ModTime().After(env.LastUpdated) {
+	// If the file has been modified since the environment was last updated, rebuild it.
+	// A save that landed while the last mirror ran predates LastUpdated, so also
+	// compare the content with the mirrored copy.
+	changed := fileInfo.ModTime().After(env.LastUpdated)
+	if relPath, err := filepath.Rel(c.sourceDir, env.OriginalPath); err == nil && !changed {
+		if mirroredHash, mirrored := env.fileHashes[relPath]; mirrored {
+			data, err := os.ReadFile(env.OriginalPath)
+			if err != nil {
+				return fmt.Errorf("error reading file %s: %w", env.OriginalPath, err)
+			}
+			changed = fileHash(data) != mirroredHash
+		}
+	}
+	if changed {
 		c.logger.Printf("Rebuilding environment for %s due to file change", env.EndpointPath)
 		if err := c.mirrorFilesToEnvironment(env); err != nil {
 			return fmt.Errorf("error rebuilding environment: %w", err)
 		}
 		env.LastUpdated = time.Now()
+		c.emit(EventEnvRebuilt, env)
 	}
 
 	return nil
 }
 
-// mirrorFilesToEnvironment mirrors all files from the source directory to the environment
+// refreshIfDue re-syncs a production environment with the source directory once
+// checkInterval has passed since the last check, so deploys that swap files on disk
+// are picked up without a restart. Only one request performs each check.
+func (c *EnvironmentCache) refreshIfDue(env *PHPEnvironment) {
+	if c.checkInterval <= 0 {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := env.lastChecked.Load()
+	if now-last < int64(c.checkInterval) || !env.lastChecked.CompareAndSwap(last, now) {
+		return
+	}
+
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	// Mirroring re-hashes every file and only copies the ones that changed
+	before := maps.Clone(env.fileHashes)
+	if err := c.mirrorFilesToEnvironment(env); err != nil {
+		// Keep serving the previous files rather than failing requests
+		c.logger.Printf("Error re-syncing environment for %s: %v", env.EndpointPath, err)
+		return
+	}
+	if !maps.Equal(before, env.fileHashes) {
+		env.LastUpdated = time.Now()
+		c.logger.Printf("Re-synced environment for %s with changed source files", env.EndpointPath)
+		c.emit(EventEnvRebuilt, env)
+	}
+}
+
+// mirrorFilesToEnvironment mirrors the source directory into the environment, copying
+// only files whose content changed since the last mirror. Callers sharing env must hold env.mutex.
 func (c *EnvironmentCache) mirrorFilesToEnvironment(env *PHPEnvironment) error {
+	// A discarded environment must not recreate its removed directory
+	if env.discarded {
+		return nil
+	}
+
 	// Get the directory containing the original file
 	sourceDir := c.sourceDir
 
 	// Mirror all files from the source directory to the environment
 	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		// Files deleted while walking (editor swap files, atomic saves) aren't errors
+		if os.IsNotExist(err) && path != sourceDir {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
@@ -1081,27 +1919,56 @@ func (c *EnvironmentCache) mirrorFilesToEnvironment(env *PHPEnvironment) error {
 		// Calculate the target path in the environment
 		targetPath := filepath.Join(env.TempPath, relPath)
 
-		// Create the directory for this file
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-			return fmt.Errorf("error creating directory for %s: %w", targetPath, err)
-		}
-
-		// Copy the file
+		// Read the file and skip it if the environment copy is already up to date
 		sourceData, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("error reading file %s: %w", path, err)
 		}
 
-		if err := os.WriteFile(targetPath, sourceData, 0644); err != nil {
+		hash := fileHash(sourceData)
+		if env.fileHashes[relPath] == hash {
+			if _, err := os.Stat(targetPath); err == nil {
+				return nil
+			}
+		}
+
+		// Create the directory for this file
+		if err := c.fileOps.mkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return fmt.Errorf("error creating directory for %s: %w", targetPath, err)
+		}
+
+		// Copy the file, forgetting the old hash first so a failed write is retried.
+		// Writing beside the target and renaming means a reader never sees a partial file.
+		delete(env.fileHashes, relPath)
+		tempTarget := targetPath + ".frango-tmp"
+		if err := c.fileOps.writeFile(tempTarget, sourceData, 0644); err != nil {
+			os.Remove(tempTarget)
+			return fmt.Errorf("error writing file %s: %w", targetPath, err)
+		}
+		if err := os.Rename(tempTarget, targetPath); err != nil {
+			os.Remove(tempTarget)
 			return fmt.Errorf("error writing file %s: %w", targetPath, err)
 		}
 
+		env.fileHashes[relPath] = hash
 		return nil
 	})
 }
 
+// fileHash returns the hex SHA-256 of a file's content
+func fileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // Cleanup removes all environments
 func (c *EnvironmentCache) Cleanup() {
+	// Let discarded environments finish removing their own files first
+	c.removals.Wait()
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -1110,10 +1977,88 @@ func (c *EnvironmentCache) Cleanup() {
 	}
 
 	c.environments = make(map[string]*PHPEnvironment)
+	c.resolved = make(map[string]resolvedScript)
 
 	c.logger.Printf("Cleaned up all environments")
 }
 
+// Clear discards every environment and returns how many were removed. Files of
+// environments still in use are removed once their requests finish.
+func (c *EnvironmentCache) Clear() int {
+	c.mutex.RLock()
+	environments := make([]*PHPEnvironment, 0, len(c.environments))
+	for _, env := range c.environments {
+		environments = append(environments, env)
+	}
+	c.mutex.RUnlock()
+
+	for _, env := range environments {
+		c.discardEnvironment(env)
+	}
+	return len(environments)
+}
+
+// discardEnvironment drops an environment from the cache, so the next request builds
+// a fresh one, and removes its files in the background once requests still running
+// scripts from it are done. The caller never waits for those requests.
+func (c *EnvironmentCache) discardEnvironment(env *PHPEnvironment) {
+	c.mutex.Lock()
+	if c.environments[env.EndpointPath] == env {
+		delete(c.environments, env.EndpointPath)
+	}
+	for key, resolved := range c.resolved {
+		if resolved.env == env {
+			delete(c.resolved, key)
+		}
+	}
+	c.mutex.Unlock()
+
+	c.removals.Add(1)
+	go c.removeEnvironment(env)
+}
+
+// removeEnvironment deletes a discarded environment's files, waiting for requests
+// still running scripts from it
+func (c *EnvironmentCache) removeEnvironment(env *PHPEnvironment) {
+	defer c.removals.Done()
+
+	env.mutex.Lock()
+	defer env.mutex.Unlock()
+
+	if env.discarded {
+		return
+	}
+	env.discarded = true
+
+	if err := os.RemoveAll(env.TempPath); err != nil {
+		c.logger.Printf("Error removing environment %s: %v", env.TempPath, err)
+	}
+	c.logger.Printf("Discarded environment for %s", env.EndpointPath)
+	c.emit(EventEnvEvicted, env)
+}
+
+// emit reports an environment lifecycle event
+func (c *EnvironmentCache) emit(eventType EventType, env *PHPEnvironment) {
+	if c.onEvent != nil {
+		c.onEvent(FrangoEvent{Type: eventType, Path: env.EndpointPath, ScriptPath: env.OriginalPath, EnvID: env.ID})
+	}
+}
+
+// resolvedScript returns the cached script location for an endpoint
+func (c *EnvironmentCache) resolvedScript(endpointPath string, originalPath string) (resolvedScript, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	resolved, found := c.resolved[endpointPath+"\x00"+originalPath]
+	return resolved, found
+}
+
+// storeResolvedScript caches a validated script location for an endpoint
+func (c *EnvironmentCache) storeResolvedScript(endpointPath string, originalPath string, resolved resolvedScript) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.resolved[endpointPath+"\x00"+originalPath] = resolved
+}
+
 // Framework-specific adapters
 
 // For Gin returns a handler function for use with Gin
@@ -1192,28 +2137,67 @@ func (m *Middleware) AddEmbeddedLibrary(
 		return ""
 	}
 
-	// Make sure targetLibraryPath starts with a slash for consistency
-	if !strings.HasPrefix(targetLibraryPath, "/") {
-		targetLibraryPath = "/" + targetLibraryPath
+	targetPath, err := m.writeLibrary(content, targetLibraryPath)
+	if err != nil {
+		m.logger.Printf("Warning: Failed to add embedded library %s: %v", embedPath, err)
+		return ""
+	}
+
+	m.logger.Printf("Added embedded PHP library at %s", targetPath)
+	return targetPath
+}
+
+// AddLibrary adds a PHP utility/library file from disk (e.g. a shared helpers.php or a
+// vendor autoloader) at targetLibraryPath in the source directory, so it's mirrored into
+// every environment and can be included from any PHP page
+func (m *Middleware) AddLibrary(diskPath string, targetLibraryPath string) string {
+	content, err := os.ReadFile(diskPath)
+	if err != nil {
+		m.logger.Printf("Error reading library file %s: %v", diskPath, err)
+		return ""
+	}
+
+	targetPath, err := m.writeLibrary(content, targetLibraryPath)
+	if err != nil {
+		m.logger.Printf("Warning: Failed to add library %s: %v", diskPath, err)
+		return ""
+	}
+
+	m.logger.Printf("Added PHP library %s at %s", diskPath, targetPath)
+	return targetPath
+}
+
+// AddVirtualFile writes generated PHP content (e.g. a config file built at startup) to
+// targetPath in the source directory and returns the written path, which can be passed
+// to ForMethods, ExecuteTo or HandlePHP. Like libraries, the file is mirrored into every
+// environment so other scripts can include it.
+func (m *Middleware) AddVirtualFile(targetPath string, content []byte) (string, error) {
+	writtenPath, err := m.writeLibrary(content, targetPath)
+	if err != nil {
+		return "", err
 	}
 
+	m.logger.Printf("Added virtual PHP file at %s", writtenPath)
+	return writtenPath, nil
+}
+
+// writeLibrary writes library content to targetLibraryPath inside the source directory
+func (m *Middleware) writeLibrary(content []byte, targetLibraryPath string) (string, error) {
+	// Cleaning a rooted path keeps ".." segments from escaping the source directory
+	targetLibraryPath = path.Clean("/" + filepath.ToSlash(targetLibraryPath))
+
 	// Create the target path
-	targetPath := filepath.Join(m.sourceDir, strings.TrimPrefix(targetLibraryPath, "/"))
+	targetPath := filepath.Join(m.sourceDir, filepath.FromSlash(strings.TrimPrefix(targetLibraryPath, "/")))
 
 	// Create directory structure
-	if targetDir := filepath.Dir(targetPath); targetDir != "" {
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			m.logger.Printf("Warning: Failed to create directory for library %s: %v", targetLibraryPath, err)
-			return ""
-		}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return "", fmt.Errorf("error creating directory for %s: %w", targetLibraryPath, err)
 	}
 
 	// Write file to disk
 	if err := os.WriteFile(targetPath, content, 0644); err != nil {
-		m.logger.Printf("Warning: Failed to write library file %s: %v", targetPath, err)
-		return ""
+		return "", fmt.Errorf("error writing %s: %w", targetPath, err)
 	}
 
-	m.logger.Printf("Added embedded PHP library at %s", targetPath)
-	return targetPath
+	return targetPath, nil
 }
@@ -0,0 +1,244 @@
+package frango
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestEnvironmentCache returns an environment cache over a source directory
+// holding the given scripts
+func newTestEnvironmentCache(t *testing.T, scripts ...string) *EnvironmentCache {
+	t.Helper()
+	sourceDir := t.TempDir()
+	for _, script := range scripts {
+		if err := os.WriteFile(filepath.Join(sourceDir, script), []byte("<?php echo 'ok';"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cache := NewEnvironmentCache(sourceDir, t.TempDir(), log.New(io.Discard, "", 0), false)
+	t.Cleanup(cache.Cleanup)
+	return cache
+}
+
+func TestEvictionDoesNotWaitForInFlightRequests(t *testing.T) {
+	cache := newTestEnvironmentCache(t, "a.php", "b.php")
+	cache.maxEnvironments = 1
+
+	envA, err := cache.GetEnvironment("/a", filepath.Join(cache.sourceDir, "a.php"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A long request (SSE, streaming) is still running a script from A
+	envA.mutex.RLock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.GetEnvironment("/b", filepath.Join(cache.sourceDir, "b.php"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		envA.mutex.RUnlock()
+		t.Fatal("creating /b blocked on the request still using the evicted /a")
+	}
+
+	cache.mutex.RLock()
+	_, stillCached := cache.environments["/a"]
+	cache.mutex.RUnlock()
+	if stillCached {
+		t.Error("/a is still cached after eviction")
+	}
+	if _, err := os.Stat(envA.TempPath); err != nil {
+		t.Errorf("/a's files were removed while a request was using them: %v", err)
+	}
+
+	// Once the request finishes, the files go
+	envA.mutex.RUnlock()
+	cache.removals.Wait()
+	if _, err := os.Stat(envA.TempPath); !os.IsNotExist(err) {
+		t.Errorf("/a's files still exist after its last request finished: %v", err)
+	}
+}
+
+func TestConcurrentEditsNeverServePartialFiles(t *testing.T) {
+	cache := newTestEnvironmentCache(t, "page.php")
+	cache.developmentMode = true
+	scriptPath := filepath.Join(cache.sourceDir, "page.php")
+
+	// Every version is large enough that a torn copy would be noticed
+	versions := make(map[string]bool)
+	version := func(i int) string {
+		return fmt.Sprintf("<?php // version %d\n%s", i, strings.Repeat(fmt.Sprintf("echo %d;\n", i), 2000))
+	}
+
+	stop := make(chan struct{})
+	var editors sync.WaitGroup
+	var mu sync.Mutex
+	editors.Add(1)
+	go func() {
+		defer editors.Done()
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			content := version(i)
+			mu.Lock()
+			versions[content] = true
+			mu.Unlock()
+
+			// Editors save by writing beside the file and renaming over it
+			tmp := scriptPath + ".swp"
+			if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := os.Rename(tmp, scriptPath); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	mu.Lock()
+	versions["<?php echo 'ok';"] = true
+	mu.Unlock()
+
+	var readers sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < 200; i++ {
+				env, err := cache.GetEnvironment("/page", scriptPath)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				// Requests read their script under the environment's read lock, and
+				// set up a fresh environment if this one was discarded meanwhile
+				env.mutex.RLock()
+				if env.discarded {
+					env.mutex.RUnlock()
+					continue
+				}
+				data, err := os.ReadFile(filepath.Join(env.TempPath, "page.php"))
+				env.mutex.RUnlock()
+				if err != nil {
+					t.Errorf("mirrored script missing: %v", err)
+					return
+				}
+				mu.Lock()
+				known := versions[string(data)]
+				mu.Unlock()
+				if !known {
+					t.Errorf("served a partial script of %d bytes", len(data))
+					return
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	close(stop)
+	editors.Wait()
+
+	// Once edits stop, the next request sees the last saved version
+	want, err := os.ReadFile(scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := cache.GetEnvironment("/page", scriptPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(env.TempPath, "page.php")); string(got) != string(want) {
+		t.Error("the environment kept a stale version after the last edit")
+	}
+}
+
+func TestRenderParamsStrictness(t *testing.T) {
+	data := map[string]interface{}{"title": "Hello", "updates": make(chan int)}
+
+	tests := []struct {
+		name            string
+		strict, devMode bool
+		wantErr         bool
+	}{
+		{"strict in development", true, true, true},
+		{"strict in production", true, false, false},
+		{"lenient", false, true, false},
+	}
+	for _, tt := range tests {
+		var logs strings.Builder
+		m := &Middleware{logger: log.New(&logs, "", 0), strictRenderData: tt.strict, developmentMode: tt.devMode}
+
+		params, err := m.renderParams(data)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), `"updates"`) {
+				t.Errorf("%s: error = %v, want one naming the updates key", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if params["frango_VAR_title"] != `"Hello"` {
+			t.Errorf("%s: title = %q, want it passed as JSON", tt.name, params["frango_VAR_title"])
+		}
+		if _, found := params["frango_VAR_updates"]; found {
+			t.Errorf("%s: the channel was passed to PHP", tt.name)
+		}
+		if !strings.Contains(logs.String(), "WARNING: Dropping render data key updates") {
+			t.Errorf("%s: no warning logged for the dropped key:\n%s", tt.name, logs.String())
+		}
+	}
+}
+
+// BenchmarkEnvironmentScriptPath resolves a script inside its environment for 10,000
+// requests per operation, with and without the production cache of validated paths
+func BenchmarkEnvironmentScriptPath(b *testing.B) {
+	const requests = 10000
+
+	for _, mode := range []struct {
+		name    string
+		devMode bool
+	}{{"production", false}, {"development", true}} {
+		b.Run(mode.name, func(b *testing.B) {
+			m, cleanup := NewTestInstance(map[string]string{"page.php": "<?php echo 'ok';"}, quietLogger(), WithDevelopmentMode(mode.devMode))
+			defer cleanup()
+
+			sourcePath := filepath.Join(m.sourceDir, "page.php")
+			r := httptest.NewRequest(http.MethodGet, "/page", nil)
+			w := httptest.NewRecorder()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < requests; j++ {
+					_, _, release, ok := m.environmentScriptPath("/page", sourcePath, "page.php", w, r)
+					if !ok {
+						b.Fatalf("resolving page.php failed: %d %s", w.Code, w.Body.String())
+					}
+					release()
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*requests), "ns/request")
+		})
+	}
+}
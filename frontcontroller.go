@@ -0,0 +1,20 @@
+package frango
+
+import "net/http"
+
+// FrontController returns a handler that sends every request to a single PHP script,
+// the way Laravel or Symfony route through public/index.php. Existing non-PHP files in
+// the source directory are served as static assets first. The script sees the original
+// URL in REQUEST_URI and PATH_INFO, and its own path in SCRIPT_NAME, so the framework's
+// router resolves the route. The script path is relative to the source directory.
+func (m *Middleware) FrontController(scriptPath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if staticPath, ok := m.staticFilePath(r.URL.Path); ok {
+			m.serveStatic(w, r, staticPath)
+			return
+		}
+
+		r = withPathInfo(r, r.URL.Path)
+		m.serveScript(scriptPath, w, r)
+	})
+}
@@ -0,0 +1,80 @@
+package frango
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// WithHeadOptimization answers HEAD requests from a cached GET response for the same
+// URL when WithResponseCache holds one, without running PHP. Other HEAD requests still
+// run the script, since its headers depend on it.
+func WithHeadOptimization(enabled bool) Option {
+	return func(m *Middleware) {
+		m.headOptimization = enabled
+	}
+}
+
+// headResponse captures the status and headers of a response and counts its body
+// bytes without keeping them
+type headResponse struct {
+	header http.Header
+	status int
+	length int64
+}
+
+// Header returns the captured response headers
+func (h *headResponse) Header() http.Header {
+	return h.header
+}
+
+// WriteHeader records the status code (only the first call counts)
+func (h *headResponse) WriteHeader(status int) {
+	if h.status == 0 {
+		h.status = status
+	}
+}
+
+// Write counts and discards body bytes
+func (h *headResponse) Write(p []byte) (int, error) {
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+	h.length += int64(len(p))
+	return len(p), nil
+}
+
+// Flush is a no-op: nothing is sent until the body length is known
+func (h *headResponse) Flush() {}
+
+// serveHead answers a HEAD request with the headers GET would send, including a
+// Content-Length matching the GET body, and no body. The response comes from the
+// response cache when allowed, or from running serve.
+func (m *Middleware) serveHead(w http.ResponseWriter, r *http.Request, serve func(w http.ResponseWriter)) {
+	if m.headOptimization && m.responseCache != nil && !hasCredentials(r) {
+		if cached, found := m.responseCache.lookup(m.responseCacheKey(r), r); found {
+			m.logger.Printf("Answering HEAD %s from response cache", r.URL.Path)
+			mergeHeaders(w.Header(), cached.Header())
+			w.Header().Set("Content-Length", strconv.Itoa(cached.body.Len()))
+			w.WriteHeader(cached.statusCode())
+			return
+		}
+	}
+
+	head := &headResponse{header: make(http.Header)}
+	serve(head)
+
+	mergeHeaders(w.Header(), head.header)
+	status := head.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if w.Header().Get("Content-Length") == "" && bodyAllowed(status) {
+		w.Header().Set("Content-Length", strconv.FormatInt(head.length, 10))
+	}
+	w.WriteHeader(status)
+}
+
+// bodyAllowed reports whether a response with the status may have a body
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,87 @@
+package frango
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+)
+
+// HealthStatus is the report served by HealthHandler
+type HealthStatus struct {
+	// Status is "ok", or "shutting_down" once Shutdown has started
+	Status string `json:"status"`
+	// Initialized reports whether PHP has been started; it starts lazily on the first request
+	Initialized bool `json:"initialized"`
+	// Environments is the number of cached script environments
+	Environments int `json:"environments"`
+	// TempDirBytes is the disk space used by the environments' temp directory
+	TempDirBytes int64 `json:"temp_dir_bytes"`
+	// ActiveRequests is the number of PHP requests currently running
+	ActiveRequests int64 `json:"active_requests"`
+	// PHPVersion is the running PHP version, empty until PHP is initialized
+	PHPVersion string `json:"php_version,omitempty"`
+}
+
+// HealthHandler returns a handler reporting the middleware's state as JSON, ready
+// to mount as a health check endpoint such as /healthz. It responds 200, or 503
+// while shutting down. It never starts PHP itself: the PHP version is only
+// reported once PHP has been initialized by a request.
+func (m *Middleware) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := m.health()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if status.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// health collects the current HealthStatus
+func (m *Middleware) health() HealthStatus {
+	status := HealthStatus{
+		Status:         "ok",
+		Initialized:    m.initialized,
+		TempDirBytes:   dirSize(m.tempDir),
+		ActiveRequests: m.activeRequests.Load(),
+	}
+	if m.shuttingDown.Load() {
+		status.Status = "shutting_down"
+	}
+
+	if m.envCache != nil {
+		m.envCache.mutex.RLock()
+		status.Environments = len(m.envCache.environments)
+		m.envCache.mutex.RUnlock()
+	}
+
+	if status.Initialized {
+		if info, err := m.Info(); err == nil {
+			status.PHPVersion = info.Version
+		} else {
+			m.logger.Printf("Health check couldn't read PHP info: %v", err)
+		}
+	}
+	return status
+}
+
+// dirSize returns the total size of the regular files under dir, skipping anything
+// that disappears or can't be read while walking
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,128 @@
+package frango
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// iniFileName is the name of the generated ini file inside the scan directory
+const iniFileName = "zz-frango.ini"
+
+// writePHPIni writes the configured ini directives into a scan directory and points
+// PHP_INI_SCAN_DIR at it so PHP loads them when FrankenPHP starts.
+// Directives are process-wide: PHP only reads its ini files once at startup.
+func (m *Middleware) writePHPIni() error {
+	// The sandbox covers directories only known once New has resolved them
+	if m.openBasedir {
+		m.phpIni["open_basedir"] = `"` + strings.Join(m.openBasedirPaths(), string(os.PathListSeparator)) + `"`
+	}
+
+	if len(m.phpIni) == 0 {
+		return nil
+	}
+
+	iniDir := filepath.Join(m.tempDir, "php.d")
+	if err := os.MkdirAll(iniDir, 0755); err != nil {
+		return fmt.Errorf("error creating ini directory: %w", err)
+	}
+
+	// Sort directives so the generated file is stable
+	keys := make([]string, 0, len(m.phpIni))
+	for key := range m.phpIni {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	content.WriteString("; Generated by frango\n")
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s = %s\n", key, m.phpIni[key])
+	}
+
+	iniPath := filepath.Join(iniDir, iniFileName)
+	if err := os.WriteFile(iniPath, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("error writing ini file: %w", err)
+	}
+
+	// A leading separator tells PHP to keep scanning its compiled-in directory too
+	scanDir := string(os.PathListSeparator) + iniDir
+	if existing := os.Getenv("PHP_INI_SCAN_DIR"); existing != "" {
+		scanDir = existing
+		if !strings.Contains(existing, iniDir) {
+			scanDir = existing + string(os.PathListSeparator) + iniDir
+		}
+	}
+	if err := os.Setenv("PHP_INI_SCAN_DIR", scanDir); err != nil {
+		return fmt.Errorf("error setting PHP_INI_SCAN_DIR: %w", err)
+	}
+
+	m.logger.Printf("Wrote %d PHP ini directives to %s", len(keys), iniPath)
+	return nil
+}
+
+// runtimeIni returns the directives the instance needs PHP to run with. The generated
+// auto_prepend_file lives in the instance's temp directory, so the user's prepend file
+// it chains stands in for it.
+func (m *Middleware) runtimeIni() map[string]string {
+	ini := maps.Clone(m.phpIni)
+	delete(ini, "auto_prepend_file")
+	if m.userPrepend != "" {
+		ini["auto_prepend_file"] = m.userPrepend
+	}
+	return ini
+}
+
+// WithDisabledFunctions disables the given PHP functions (e.g. exec, system, shell_exec)
+// through the disable_functions ini directive. Like all startup directives it applies
+// to every script run by the process, not just this instance's; instances sharing PHP
+// must configure the same directives.
+func WithDisabledFunctions(functions []string) Option {
+	return func(m *Middleware) {
+		if len(functions) == 0 {
+			return
+		}
+		m.phpIni["disable_functions"] = strings.Join(functions, ",")
+	}
+}
+
+// WithOpenBasedir confines the files PHP scripts can open through the open_basedir
+// ini directive: the source directory, frango's environment directory, the system temp
+// directory (uploads and sessions) and any extra directories, relative ones being
+// resolved against the source directory. Like all startup directives it applies to
+// every script run by the process, so the sandbox covers every instance or none: an
+// instance whose directives differ from the running PHP's fails to initialize.
+func WithOpenBasedir(extraDirs ...string) Option {
+	return func(m *Middleware) {
+		m.openBasedir = true
+		m.openBasedirDirs = append(m.openBasedirDirs, extraDirs...)
+	}
+}
+
+// openBasedirPaths returns the directories PHP may open files in under WithOpenBasedir
+func (m *Middleware) openBasedirPaths() []string {
+	paths := []string{m.sourceDir, m.tempDir, os.TempDir()}
+	for _, dir := range m.openBasedirDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(m.sourceDir, dir)
+		}
+		paths = append(paths, dir)
+	}
+	return paths
+}
+
+// WithPHPIni sets php.ini directives such as memory_limit, upload_max_filesize or
+// error_reporting. Values are written verbatim, so constants like E_ALL work.
+// Directives are loaded once when PHP starts and apply to every executed script,
+// including those that take effect before the request body is parsed.
+// Repeated calls merge, with later values winning.
+func WithPHPIni(directives map[string]string) Option {
+	return func(m *Middleware) {
+		for key, value := range directives {
+			m.phpIni[key] = value
+		}
+	}
+}
@@ -0,0 +1,51 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sandboxScript reports whether a disabled function and a file outside the sandbox
+// are reachable, and that ordinary functions still work
+const sandboxScript = `<?php
+try {
+    exec('true');
+    echo "exec:allowed\n";
+} catch (\Error $e) {
+    echo "exec:disabled\n";
+}
+echo 'strlen:' . strlen('frango') . "\n";
+echo @file_get_contents('/etc/hostname') === false ? "outside:blocked\n" : "outside:readable\n";
+`
+
+func TestDisabledFunctionsInPHP(t *testing.T) {
+	// PHP reads its ini once per process, so the sandbox only applies if this test
+	// is the one that starts it
+	phpRuntime.mutex.Lock()
+	running := phpRuntime.users > 0
+	phpRuntime.mutex.Unlock()
+	if running {
+		t.Skip("PHP already started by another test, ini directives can't change")
+	}
+
+	m, cleanup := NewTestInstance(map[string]string{"check.php": sandboxScript},
+		quietLogger(),
+		WithDisabledFunctions([]string{"exec", "system", "shell_exec"}),
+		WithOpenBasedir(),
+	)
+	defer cleanup()
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/check.php", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	want := "exec:disabled\nstrlen:6\noutside:blocked\n"
+	if recorder.Body.String() != want {
+		t.Errorf("body = %q, want %q", recorder.Body.String(), want)
+	}
+}
@@ -0,0 +1,61 @@
+package frango
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWritePHPIniSandboxDirectives(t *testing.T) {
+	// writePHPIni points PHP_INI_SCAN_DIR at the generated file; restore it afterwards
+	t.Setenv("PHP_INI_SCAN_DIR", "")
+
+	m, cleanup := NewTestInstance(nil,
+		quietLogger(),
+		WithDisabledFunctions([]string{"exec", "system", "shell_exec"}),
+		WithOpenBasedir("shared", "/opt/php-libs"),
+	)
+	defer cleanup()
+
+	if err := m.writePHPIni(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(m.tempDir, "php.d", iniFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ini := string(content)
+
+	if !strings.Contains(ini, "disable_functions = exec,system,shell_exec\n") {
+		t.Errorf("disable_functions missing from generated ini:\n%s", ini)
+	}
+
+	separator := string(os.PathListSeparator)
+	wantBasedir := `open_basedir = "` + strings.Join([]string{
+		m.sourceDir,
+		m.tempDir,
+		os.TempDir(),
+		filepath.Join(m.sourceDir, "shared"),
+		"/opt/php-libs",
+	}, separator) + `"` + "\n"
+	if !strings.Contains(ini, wantBasedir) {
+		t.Errorf("open_basedir line missing from generated ini, want %q in:\n%s", wantBasedir, ini)
+	}
+
+	if scanDir := os.Getenv("PHP_INI_SCAN_DIR"); !strings.Contains(scanDir, filepath.Join(m.tempDir, "php.d")) {
+		t.Errorf("PHP_INI_SCAN_DIR = %q doesn't include the generated directory", scanDir)
+	}
+}
+
+func TestWithDisabledFunctionsEmpty(t *testing.T) {
+	m, cleanup := NewTestInstance(nil, quietLogger(), WithDisabledFunctions(nil))
+	defer cleanup()
+
+	if _, set := m.phpIni["disable_functions"]; set {
+		t.Error("an empty list set disable_functions")
+	}
+	if _, set := m.phpIni["open_basedir"]; set {
+		t.Error("open_basedir set without WithOpenBasedir")
+	}
+}
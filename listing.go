@@ -0,0 +1,74 @@
+package frango
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WithDirectoryListing serves a simple HTML listing of the PHP routes and
+// subdirectories of a source directory that has no index file, instead of a 404.
+// Scripts are listed by their clean URL (without .php). Useful for development dashboards.
+func WithDirectoryListing(enabled bool) Option {
+	return func(m *Middleware) {
+		m.directoryListing = enabled
+	}
+}
+
+// serveDirectoryListing renders the PHP routes available in dirPath, served at urlDir
+func (m *Middleware) serveDirectoryListing(w http.ResponseWriter, urlDir string, dirPath string) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		m.logger.Printf("Error listing directory %s: %v", dirPath, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	var links []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// Dotfiles and underscore-prefixed includes aren't routes
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			continue
+		}
+		// Neither are files WithBlockedPaths hides
+		entryPath := urlDir + name
+		if entry.IsDir() {
+			entryPath += "/"
+		}
+		if m.isBlockedPath(entryPath) {
+			continue
+		}
+		if entry.IsDir() {
+			links = append(links, name+"/")
+		} else if ext, isPHP := m.phpExtension(name); isPHP {
+			links = append(links, strings.TrimSuffix(name, ext))
+		}
+	}
+	sort.Strings(links)
+
+	var body strings.Builder
+	title := html.EscapeString(urlDir)
+	fmt.Fprintf(&body, "<!DOCTYPE html>\n<html>\n<head><title>Index of %s</title></head>\n<body>\n<h1>Index of %s</h1>\n<ul>\n", title, title)
+	if urlDir != "/" {
+		body.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, link := range links {
+		escaped := html.EscapeString(link)
+		fmt.Fprintf(&body, "<li><a href=\"%s%s\">%s</a></li>\n", title, escaped, escaped)
+	}
+	body.WriteString("</ul>\n</body>\n</html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(body.String()))
+}
+
+// inSourceDir reports whether a cleaned file path is the source directory or inside it
+func (m *Middleware) inSourceDir(filePath string) bool {
+	relPath, err := filepath.Rel(m.sourceDir, filePath)
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,76 @@
+package frango
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RouteManifest is the JSON a PHP route script outputs for LoadRoutesFromPHP:
+//
+//	{
+//	  "routes": [
+//	    {"method": "GET", "pattern": "/users", "script": "users/index.php"},
+//	    {"pattern": "/about", "script": "about.php"}
+//	  ]
+//	}
+//
+// Method is optional (any method when empty) and scripts are relative to the source directory.
+type RouteManifest struct {
+	Routes []RouteManifestEntry `json:"routes"`
+}
+
+// RouteManifestEntry is a single route declared by a PHP route script
+type RouteManifestEntry struct {
+	Method  string `json:"method,omitempty"`
+	Pattern string `json:"pattern"`
+	Script  string `json:"script"`
+}
+
+// LoadRoutesFromPHP runs a PHP script that prints a JSON RouteManifest, registers
+// every declared route and returns them. This lets PHP own its routing table while
+// Go serves it.
+func (m *Middleware) LoadRoutesFromPHP(scriptPath string) ([]RouteInfo, error) {
+	var output bytes.Buffer
+	if err := m.ExecuteTo(context.Background(), scriptPath, nil, &output); err != nil {
+		return nil, fmt.Errorf("error running route script %s: %w", scriptPath, err)
+	}
+	return m.registerManifest(scriptPath, output.Bytes())
+}
+
+// registerManifest parses a JSON RouteManifest printed by scriptPath and registers
+// its routes
+func (m *Middleware) registerManifest(scriptPath string, output []byte) ([]RouteInfo, error) {
+	var manifest RouteManifest
+	if err := json.Unmarshal(output, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing route manifest from %s: %w", scriptPath, err)
+	}
+
+	routes := make([]RouteInfo, 0, len(manifest.Routes))
+	for i, entry := range manifest.Routes {
+		if entry.Pattern == "" || entry.Script == "" {
+			return nil, fmt.Errorf("route %d in manifest from %s needs a pattern and a script", i, scriptPath)
+		}
+
+		route := RouteInfo{
+			Method:     strings.ToUpper(entry.Method),
+			Pattern:    entry.Pattern,
+			ScriptPath: m.absScriptPath(entry.Script),
+		}
+		if !strings.HasPrefix(route.Pattern, "/") {
+			route.Pattern = "/" + route.Pattern
+		}
+
+		if route.Method != "" {
+			m.Handle(route.Method+" "+route.Pattern, route.ScriptPath)
+		} else {
+			m.Handle(route.Pattern, route.ScriptPath)
+		}
+		routes = append(routes, route)
+	}
+
+	m.logger.Printf("Loaded %d routes from %s", len(routes), scriptPath)
+	return routes, nil
+}
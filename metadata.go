@@ -0,0 +1,116 @@
+package frango
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResponseMeta describes a response that can be computed without running PHP
+type ResponseMeta struct {
+	// ETag is the entity tag, including quotes (e.g. `"v42"` or `W/"v42"`)
+	ETag string
+	// LastModified is when the resource last changed
+	LastModified time.Time
+	// ContentType is the response content type
+	ContentType string
+	// ContentLength is the body size reported for HEAD requests, nil if unknown
+	ContentLength *int64
+}
+
+// MetadataProvider computes response metadata for a request
+type MetadataProvider func(r *http.Request) ResponseMeta
+
+// metadataConfig holds WithMetadataProvider arguments until the source directory is resolved
+type metadataConfig struct {
+	scriptPath string
+	provider   MetadataProvider
+}
+
+// WithMetadataProvider lets frango answer HEAD requests and conditional GETs
+// (If-None-Match, If-Modified-Since) for a script from metadata computed in Go,
+// running PHP only when a full body is needed. The script path is relative to
+// the source directory.
+func WithMetadataProvider(scriptPath string, fn MetadataProvider) Option {
+	return func(m *Middleware) {
+		m.metadataConfigs = append(m.metadataConfigs, metadataConfig{scriptPath, fn})
+	}
+}
+
+// resolveMetadataProviders keys the configured providers by absolute script path
+func (m *Middleware) resolveMetadataProviders() {
+	for _, config := range m.metadataConfigs {
+		m.metadataProviders[m.absScriptPath(config.scriptPath)] = config.provider
+	}
+}
+
+// serveFromMetadata answers the request from the script's metadata provider when
+// possible and reports whether it did. For GETs that need a body it only sets the
+// validator headers and leaves the rest to PHP.
+func (m *Middleware) serveFromMetadata(w http.ResponseWriter, r *http.Request, sourcePath string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	provider, found := m.metadataProviders[sourcePath]
+	if !found {
+		return false
+	}
+
+	meta := provider(r)
+	if meta.ETag != "" {
+		w.Header().Set("ETag", meta.ETag)
+	}
+	if !meta.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", meta.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if meta.ContentType != "" {
+		w.Header().Set("Content-Type", meta.ContentType)
+	}
+
+	if notModified(r, meta) {
+		m.logger.Printf("Answered %s %s from metadata: not modified", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	if r.Method == http.MethodHead {
+		if meta.ContentLength != nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(*meta.ContentLength, 10))
+		}
+		m.logger.Printf("Answered HEAD %s from metadata", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		return true
+	}
+
+	return false
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since
+func notModified(r *http.Request, meta ResponseMeta) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if meta.ETag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// Weak comparison: W/"x" matches "x"
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(meta.ETag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !meta.LastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have one-second precision
+		return !meta.LastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
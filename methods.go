@@ -0,0 +1,55 @@
+package frango
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ForMethods returns a handler that runs a single PHP script for the given HTTP
+// methods and answers any other method with 405 Method Not Allowed and an Allow
+// header, before PHP is invoked. HEAD is allowed whenever GET is. The script path
+// is relative to the source directory.
+func (m *Middleware) ForMethods(scriptPath string, methods ...string) http.Handler {
+	allowed := make(map[string]bool)
+	var allowList []string
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if !allowed[method] {
+			allowed[method] = true
+			allowList = append(allowList, method)
+		}
+	}
+	if allowed[http.MethodGet] && !allowed[http.MethodHead] {
+		allowed[http.MethodHead] = true
+		allowList = append(allowList, http.MethodHead)
+	}
+	allowHeader := strings.Join(allowList, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed[r.Method] {
+			w.Header().Set("Allow", allowHeader)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		m.serveScript(scriptPath, w, r)
+	})
+}
+
+// serveScript runs a single PHP script, given relative to the source directory or absolute
+func (m *Middleware) serveScript(scriptPath string, w http.ResponseWriter, r *http.Request) {
+	if err := m.ensureInitialized(r.Context()); err != nil {
+		m.logger.Printf("Error initializing PHP environment: %v", err)
+		http.Error(w, "PHP initialization error", http.StatusInternalServerError)
+		return
+	}
+
+	absPath, urlPath, err := m.scriptURLPath(scriptPath)
+	if err != nil {
+		m.logger.Printf("Error resolving script %s: %v", scriptPath, err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	m.servePHPFile(urlPath, absPath, w, r)
+}
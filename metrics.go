@@ -0,0 +1,42 @@
+package frango
+
+import (
+	"net/http"
+	"time"
+)
+
+// MetricsCollector receives one observation per PHP request, e.g. to feed Prometheus
+type MetricsCollector interface {
+	// ObserveRequest records a served script, its response status and how long it took
+	ObserveRequest(scriptPath string, status int, duration time.Duration)
+}
+
+// noopMetrics is the default collector and discards observations
+type noopMetrics struct{}
+
+// ObserveRequest does nothing
+func (noopMetrics) ObserveRequest(scriptPath string, status int, duration time.Duration) {}
+
+// WithMetrics reports every PHP request to collector with its script path, response
+// status (500 for requests PHP couldn't serve) and duration
+func WithMetrics(collector MetricsCollector) Option {
+	return func(m *Middleware) {
+		if collector == nil {
+			collector = noopMetrics{}
+		}
+		m.metrics = collector
+	}
+}
+
+// observeRequest wraps w to capture the status and returns the writer to use and a
+// function reporting the request to the metrics collector
+func (m *Middleware) observeRequest(w http.ResponseWriter, scriptPath string, started time.Time) (http.ResponseWriter, func()) {
+	if _, disabled := m.metrics.(noopMetrics); disabled {
+		return w, func() {}
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w}
+	return recorder, func() {
+		m.metrics.ObserveRequest(scriptPath, recorder.statusCode(), time.Since(started))
+	}
+}
@@ -0,0 +1,104 @@
+package frango
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is a single media range from an Accept header
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// WithContentTypes enables content negotiation: the request's Accept header is matched
+// against types (in order of preference, e.g. "text/html", "application/json") and the
+// best match is passed to PHP as $_SERVER['FRANGO_ACCEPT_TYPE'] (also frango_ACCEPT_TYPE,
+// like the other frango_ variables) and $_NEGOTIATED.
+// Negotiated responses carry Vary: Accept.
+func WithContentTypes(types ...string) Option {
+	return func(m *Middleware) {
+		m.contentTypes = append(m.contentTypes, types...)
+	}
+}
+
+// addNegotiatedEnv passes the negotiated content type to PHP
+func (m *Middleware) addNegotiatedEnv(w http.ResponseWriter, r *http.Request, env map[string]string) {
+	if len(m.contentTypes) == 0 {
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	acceptType := negotiateContentType(r.Header.Values("Accept"), m.contentTypes)
+	env["FRANGO_ACCEPT_TYPE"] = acceptType
+	env["frango_ACCEPT_TYPE"] = acceptType
+}
+
+// negotiateContentType returns the offered type the client prefers. Ties go to the
+// earlier offer, and the first offer is used when the client accepts none of them.
+func negotiateContentType(acceptHeaders []string, offers []string) string {
+	ranges := parseAccept(acceptHeaders)
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	best, bestQuality := offers[0], 0.0
+	for _, offer := range offers {
+		if quality := acceptQuality(ranges, offer); quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best
+}
+
+// parseAccept splits Accept header values into media ranges with their quality
+func parseAccept(values []string) []acceptRange {
+	var ranges []acceptRange
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			params := strings.Split(part, ";")
+			mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+			if mediaType == "" {
+				continue
+			}
+
+			quality := 1.0
+			for _, param := range params[1:] {
+				name, raw, found := strings.Cut(strings.TrimSpace(param), "=")
+				if found && strings.TrimSpace(name) == "q" {
+					if q, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+						quality = q
+					}
+				}
+			}
+			ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching mediaType
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	mediaType = strings.ToLower(mediaType)
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, r := range ranges {
+		var matched int
+		switch r.mediaType {
+		case mediaType:
+			matched = 2
+		case mainType + "/*":
+			matched = 1
+		case "*/*":
+			matched = 0
+		default:
+			continue
+		}
+		if matched > specificity {
+			quality, specificity = r.quality, matched
+		}
+	}
+	return quality
+}
@@ -0,0 +1,39 @@
+package frango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiatedEnv(t *testing.T) {
+	m := &Middleware{}
+	WithContentTypes("text/html", "application/json")(m)
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "text/html"},
+		{"application/json", "application/json"},
+		{"text/html;q=0.5, application/json", "application/json"},
+		{"*/*", "text/html"},
+		{"image/png", "text/html"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		recorder := httptest.NewRecorder()
+		env := make(map[string]string)
+		m.addNegotiatedEnv(recorder, r, env)
+
+		if env["FRANGO_ACCEPT_TYPE"] != tt.want || env["frango_ACCEPT_TYPE"] != tt.want {
+			t.Errorf("Accept %q: FRANGO_ACCEPT_TYPE = %q, frango_ACCEPT_TYPE = %q, want %q", tt.accept, env["FRANGO_ACCEPT_TYPE"], env["frango_ACCEPT_TYPE"], tt.want)
+		}
+		if recorder.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, recorder.Header().Get("Vary"))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package frango
+
+import "net/http"
+
+// defaultContentType matches PHP's default_mimetype and default_charset
+const defaultContentType = "text/html; charset=UTF-8"
+
+// defaultHeadersWriter adds headers PHP responses don't set themselves (X-Content-Type-Options,
+// a default Content-Type and WithDefaultHeaders) just before the headers are sent
+type defaultHeadersWriter struct {
+	http.ResponseWriter
+	noSniff     bool
+	defaults    map[string]string
+	wroteHeader bool
+}
+
+// WriteHeader applies the header defaults before sending a final status
+func (n *defaultHeadersWriter) WriteHeader(status int) {
+	// 1xx responses (e.g. 103 Early Hints) are followed by the real headers
+	if status >= http.StatusOK && !n.wroteHeader {
+		n.wroteHeader = true
+		n.applyDefaults(status)
+	}
+	n.ResponseWriter.WriteHeader(status)
+}
+
+// Write applies the header defaults if PHP writes output without a status
+func (n *defaultHeadersWriter) Write(p []byte) (int, error) {
+	if !n.wroteHeader {
+		n.WriteHeader(http.StatusOK)
+	}
+	return n.ResponseWriter.Write(p)
+}
+
+// Flush passes PHP flush() calls through to the underlying writer
+func (n *defaultHeadersWriter) Flush() {
+	if flusher, ok := n.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (n *defaultHeadersWriter) Unwrap() http.ResponseWriter {
+	return n.ResponseWriter
+}
+
+// applyDefaults sets the headers the script left out
+func (n *defaultHeadersWriter) applyDefaults(status int) {
+	header := n.Header()
+	for key, value := range n.defaults {
+		if header.Get(key) == "" {
+			header.Set(key, value)
+		}
+	}
+	if !n.noSniff {
+		return
+	}
+	if header.Get("X-Content-Type-Options") == "" {
+		header.Set("X-Content-Type-Options", "nosniff")
+	}
+	// Responses without a body don't need a Content-Type
+	if header.Get("Content-Type") == "" && status != http.StatusNoContent && status != http.StatusNotModified {
+		header.Set("Content-Type", defaultContentType)
+	}
+}
+
+// WithNoSniff controls whether PHP responses get X-Content-Type-Options: nosniff and,
+// when the script removed it, a default text/html Content-Type, so browsers don't
+// MIME-sniff PHP output. Enabled by default.
+func WithNoSniff(enabled bool) Option {
+	return func(m *Middleware) {
+		m.noSniff = enabled
+	}
+}
+
+// WithDefaultHeaders sets headers on every PHP response that the script doesn't set
+// itself, e.g. X-Frame-Options or Content-Security-Policy. A header() call in PHP for
+// the same name wins over the default. Repeated calls merge, with later values winning.
+func WithDefaultHeaders(headers map[string]string) Option {
+	return func(m *Middleware) {
+		for key, value := range headers {
+			m.defaultHeaders[http.CanonicalHeaderKey(key)] = value
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package frango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNoSniff(t *testing.T) {
+	m, cleanup := NewTestInstance(nil, quietLogger())
+	defer cleanup()
+	if !m.noSniff {
+		t.Fatal("nosniff is off by default")
+	}
+	disabled, cleanup := NewTestInstance(nil, quietLogger(), WithNoSniff(false))
+	defer cleanup()
+	if disabled.noSniff {
+		t.Fatal("WithNoSniff(false) left nosniff on")
+	}
+
+	tests := []struct {
+		name        string
+		noSniff     bool
+		phpHeaders  map[string]string
+		status      int
+		wantNoSniff string
+		wantType    string
+	}{
+		{"default", true, nil, http.StatusOK, "nosniff", defaultContentType},
+		{"disabled", false, nil, http.StatusOK, "", ""},
+		{"set by PHP", true, map[string]string{"X-Content-Type-Options": "custom", "Content-Type": "application/json"}, http.StatusOK, "custom", "application/json"},
+		{"no content", true, nil, http.StatusNoContent, "nosniff", ""},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		w := &defaultHeadersWriter{ResponseWriter: recorder, noSniff: tt.noSniff}
+		for key, value := range tt.phpHeaders {
+			w.Header().Set(key, value)
+		}
+		if tt.status == http.StatusOK {
+			w.Write([]byte("output"))
+		} else {
+			w.WriteHeader(tt.status)
+		}
+
+		if got := recorder.Header().Get("X-Content-Type-Options"); got != tt.wantNoSniff {
+			t.Errorf("%s: X-Content-Type-Options = %q, want %q", tt.name, got, tt.wantNoSniff)
+		}
+		if got := recorder.Header().Get("Content-Type"); got != tt.wantType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.name, got, tt.wantType)
+		}
+	}
+}
+
+func TestNoSniffWaitsForFinalStatus(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := &defaultHeadersWriter{ResponseWriter: recorder, noSniff: true}
+
+	// Headers set after 103 Early Hints still count as PHP's own
+	w.WriteHeader(http.StatusEarlyHints)
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type = %q, want PHP's text/plain", got)
+	}
+}
+
+func TestDefaultHeadersYieldToPHP(t *testing.T) {
+	m, cleanup := NewTestInstance(nil, quietLogger(),
+		WithDefaultHeaders(map[string]string{"x-frame-options": "DENY", "Content-Security-Policy": "default-src 'self'"}),
+		WithDefaultHeaders(map[string]string{"X-Frame-Options": "SAMEORIGIN", "Referrer-Policy": "no-referrer"}))
+	defer cleanup()
+
+	recorder := httptest.NewRecorder()
+	w := &defaultHeadersWriter{ResponseWriter: recorder, defaults: m.defaultHeaders}
+
+	// What header() calls in the script leave on the response
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Add("Referrer-Policy", "origin")
+	w.Write([]byte("output"))
+
+	for header, want := range map[string]string{
+		"X-Frame-Options":         "SAMEORIGIN",
+		"Content-Security-Policy": "default-src 'none'",
+		"Referrer-Policy":         "origin",
+	} {
+		if got := recorder.Header().Values(header); len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %q, want only %q", header, got, want)
+		}
+	}
+	if got := recorder.Header().Get("X-Content-Type-Options"); got != "" {
+		t.Errorf("X-Content-Type-Options = %q, want it left to the nosniff setting", got)
+	}
+}
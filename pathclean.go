@@ -0,0 +1,66 @@
+package frango
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PathCleaningPolicy controls how request paths with duplicate slashes or dot
+// segments are handled before routing
+type PathCleaningPolicy int
+
+const (
+	// PathPassthrough routes paths exactly as received (the default)
+	PathPassthrough PathCleaningPolicy = iota
+	// PathClean collapses duplicate slashes and "." segments (/users//./42 -> /users/42)
+	// and rejects paths containing ".." segments with 400 Bad Request
+	PathClean
+	// PathReject answers any non-canonical path with 400 Bad Request
+	PathReject
+)
+
+// WithPathCleaning sets how non-canonical request paths are handled
+func WithPathCleaning(policy PathCleaningPolicy) Option {
+	return func(m *Middleware) {
+		m.pathCleaning = policy
+	}
+}
+
+// cleanRequestPath applies the path cleaning policy. It returns the request to
+// route, or false if the path must be rejected.
+func (m *Middleware) cleanRequestPath(r *http.Request) (*http.Request, bool) {
+	if m.pathCleaning == PathPassthrough {
+		return r, true
+	}
+
+	original := r.URL.Path
+	cleaned := path.Clean("/" + original)
+	// path.Clean drops trailing slashes, which are meaningful for directory routes
+	if strings.HasSuffix(original, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	if cleaned == original {
+		return r, true
+	}
+
+	if m.pathCleaning == PathReject || hasDotDotSegment(original) {
+		m.logger.Printf("Rejected non-canonical path: %s", original)
+		return r, false
+	}
+
+	cleanedRequest := r.Clone(r.Context())
+	cleanedRequest.URL.Path = cleaned
+	cleanedRequest.URL.RawPath = ""
+	return cleanedRequest, true
+}
+
+// hasDotDotSegment reports whether a path contains a ".." segment
+func hasDotDotSegment(urlPath string) bool {
+	for _, segment := range strings.Split(urlPath, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newPathCleaningMiddleware returns a bare Middleware with the given cleaning policy
+func newPathCleaningMiddleware(policy PathCleaningPolicy) *Middleware {
+	return &Middleware{pathCleaning: policy, logger: log.New(io.Discard, "", 0)}
+}
+
+// requestWithRawPath builds a request whose path is kept exactly as given
+func requestWithRawPath(rawPath string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.URL.Path = rawPath
+	return r
+}
+
+func TestCleanRequestPath(t *testing.T) {
+	tests := []struct {
+		policy PathCleaningPolicy
+		path   string
+		want   string
+		ok     bool
+	}{
+		{PathClean, "/users//42", "/users/42", true},
+		{PathClean, "/users/./42", "/users/42", true},
+		{PathClean, "//users///42/", "/users/42/", true},
+		{PathClean, "/users/42", "/users/42", true},
+		{PathClean, "/users/../admin", "", false},
+		{PathClean, "/../etc/passwd", "", false},
+		{PathReject, "/users//42", "", false},
+		{PathReject, "/users/42", "/users/42", true},
+		{PathPassthrough, "/users//42", "/users//42", true},
+		{PathPassthrough, "/users/../admin", "/users/../admin", true},
+	}
+
+	for _, tt := range tests {
+		m := newPathCleaningMiddleware(tt.policy)
+		cleaned, ok := m.cleanRequestPath(requestWithRawPath(tt.path))
+		if ok != tt.ok {
+			t.Errorf("policy %d, %s: ok = %v, want %v", tt.policy, tt.path, ok, tt.ok)
+			continue
+		}
+		if ok && cleaned.URL.Path != tt.want {
+			t.Errorf("policy %d, %s: cleaned to %s, want %s", tt.policy, tt.path, cleaned.URL.Path, tt.want)
+		}
+	}
+}
+
+func TestCleanedPathMatchesPattern(t *testing.T) {
+	m := newPathCleaningMiddleware(PathClean)
+	cleaned, ok := m.cleanRequestPath(requestWithRawPath("/users//42"))
+	if !ok {
+		t.Fatal("/users//42 was rejected")
+	}
+
+	var id string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id = r.PathValue("id")
+	})
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, cleaned)
+
+	if recorder.Code != http.StatusOK || id != "42" {
+		t.Errorf("/users//42 cleaned to %s matched with id=%q (status %d), want id=42", cleaned.URL.Path, id, recorder.Code)
+	}
+}
+
+func TestCleanRequestPathLeavesOriginalUntouched(t *testing.T) {
+	m := newPathCleaningMiddleware(PathClean)
+	original := requestWithRawPath("/users//42")
+	m.cleanRequestPath(original)
+	if original.URL.Path != "/users//42" {
+		t.Errorf("the caller's request was modified: %s", original.URL.Path)
+	}
+}
@@ -0,0 +1,61 @@
+package frango
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// pathInfoKey is the context key carrying the PATH_INFO computed for a request
+type pathInfoKey struct{}
+
+// withPathInfo returns a shallow copy of r carrying pathInfo for PHP
+func withPathInfo(r *http.Request, pathInfo string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), pathInfoKey{}, pathInfo))
+}
+
+// addPathInfoEnv sets PATH_INFO, and PHP_SELF to match, when the request carries one
+func addPathInfoEnv(r *http.Request, env map[string]string) {
+	pathInfo, ok := r.Context().Value(pathInfoKey{}).(string)
+	if !ok {
+		return
+	}
+	env["PATH_INFO"] = pathInfo
+	env["PHP_SELF"] = env["SCRIPT_NAME"] + pathInfo
+}
+
+// matchPrefixRoute finds the longest route explicitly mounted with a trailing slash
+// (other than "/") that path falls under, like http.ServeMux subtree patterns. The
+// directory routes HandleDir adds for index files aren't mounts. Method-specific
+// routes win over plain ones for the same prefix. It returns the matched pattern, its
+// script and the rest of the path, which PHP receives as PATH_INFO.
+func (m *Middleware) matchPrefixRoute(method string, path string) (string, string, string, bool) {
+	var pattern, phpFile string
+	for key := range m.prefixRoutes {
+		file, exists := m.routes[key]
+		if !exists {
+			continue
+		}
+		prefix := key
+		if i := strings.Index(key, ":"); i != -1 {
+			if key[:i] != method {
+				continue
+			}
+			prefix = key[i+1:]
+		}
+
+		if prefix == "/" || !strings.HasSuffix(prefix, "/") || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+
+		// Prefer the longest prefix, then a method-specific route
+		if len(prefix) > len(pattern) || (len(prefix) == len(pattern) && prefix != key) {
+			pattern, phpFile = prefix, file
+		}
+	}
+
+	if pattern == "" {
+		return "", "", "", false
+	}
+	return pattern, phpFile, "/" + strings.TrimPrefix(path, pattern), true
+}
@@ -0,0 +1,95 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// quietLogger discards the middleware's log output in tests
+func quietLogger() Option {
+	return WithLogger(log.New(io.Discard, "", 0))
+}
+
+// serve runs a request through the middleware
+func serve(h http.Handler, method string, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+func TestMatchPrefixRouteExplicitMount(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"api/router.php":    "<?php",
+		"api/v2/router.php": "<?php",
+	}, quietLogger())
+	defer cleanup()
+
+	m.HandlePHP("/api/", "api/router.php")
+	m.HandlePHP("/api/v2/", "api/v2/router.php")
+
+	tests := []struct {
+		path     string
+		pattern  string
+		pathInfo string
+	}{
+		{"/api/users/42", "/api/", "/users/42"},
+		{"/api/", "/api/", "/"},
+		{"/api/v2/items", "/api/v2/", "/items"},
+	}
+	for _, tt := range tests {
+		pattern, _, pathInfo, found := m.matchPrefixRoute("GET", tt.path)
+		if !found || pattern != tt.pattern || pathInfo != tt.pathInfo {
+			t.Errorf("matchPrefixRoute(%s) = %q, %q, %v; want %q, %q", tt.path, pattern, pathInfo, found, tt.pattern, tt.pathInfo)
+		}
+	}
+
+	if _, _, _, found := m.matchPrefixRoute("GET", "/apiary"); found {
+		t.Error("/apiary matched the /api/ mount")
+	}
+}
+
+func TestMatchPrefixRouteMethodSpecific(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"api/router.php": "<?php",
+		"api/write.php":  "<?php",
+	}, quietLogger())
+	defer cleanup()
+
+	m.HandlePHP("/api/", "api/router.php")
+	m.Handle("POST /api/", m.sourceDir+"/api/write.php")
+
+	if _, phpFile, _, _ := m.matchPrefixRoute("POST", "/api/items"); phpFile != m.sourceDir+"/api/write.php" {
+		t.Errorf("POST /api/items ran %s, want the method-specific mount", phpFile)
+	}
+	if _, phpFile, _, _ := m.matchPrefixRoute("GET", "/api/items"); phpFile != m.sourceDir+"/api/router.php" {
+		t.Errorf("GET /api/items ran %s, want the plain mount", phpFile)
+	}
+}
+
+func TestHandleDirIndexIsNotAPrefixMount(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"docs/index.php": "<?php",
+		"docs/guide.php": "<?php",
+	}, quietLogger())
+	defer cleanup()
+
+	if err := m.HandleDir("/", m.sourceDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := m.routes["/docs/"]; !found {
+		t.Fatal("HandleDir didn't register the /docs/ index route")
+	}
+	if _, _, _, found := m.matchPrefixRoute("GET", "/docs/missing"); found {
+		t.Error("/docs/missing matched the /docs/ index route")
+	}
+	if m.shouldHandlePHP(httptest.NewRequest("GET", "/docs/missing", nil)) {
+		t.Error("Wrap would handle /docs/missing instead of passing it on")
+	}
+	if !m.shouldHandlePHP(httptest.NewRequest("GET", "/docs/", nil)) {
+		t.Error("Wrap wouldn't handle the /docs/ index")
+	}
+}
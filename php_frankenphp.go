@@ -0,0 +1,55 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dunglas/frankenphp"
+)
+
+// startPHP initializes FrankenPHP, booting a worker pool for each script in workers
+// (script path -> pool size, 0 for FrankenPHP's default) and numThreads PHP threads
+// (0 for FrankenPHP's default)
+func startPHP(workers map[string]int, numThreads int) error {
+	options := make([]frankenphp.Option, 0, len(workers)+1)
+	for scriptPath, num := range workers {
+		options = append(options, frankenphp.WithWorkers(scriptPath, num, nil, nil))
+	}
+	if numThreads > 0 {
+		options = append(options, frankenphp.WithNumThreads(numThreads))
+	}
+	return frankenphp.Init(options...)
+}
+
+// stopPHP shuts FrankenPHP down
+func stopPHP() {
+	frankenphp.Shutdown()
+}
+
+// newPHPRequest prepares a request for FrankenPHP to execute the script under documentRoot
+func newPHPRequest(r *http.Request, documentRoot string, env map[string]string) (*http.Request, error) {
+	return frankenphp.NewRequestWithContext(
+		r,
+		frankenphp.WithRequestDocumentRoot(documentRoot, false), // Document root is the environment directory
+		frankenphp.WithRequestEnv(env),                          // Environment includes SCRIPT_FILENAME
+	)
+}
+
+// servePHPRequest executes a request prepared by newPHPRequest
+func servePHPRequest(w http.ResponseWriter, r *http.Request) error {
+	return frankenphp.ServeHTTP(w, r)
+}
+
+// phpErrorCategory classifies an error returned by FrankenPHP
+func phpErrorCategory(err error) PHPErrorCategory {
+	switch {
+	case errors.Is(err, frankenphp.NotRunningError):
+		return PHPErrorUnavailable
+	case errors.Is(err, frankenphp.InvalidRequestError), errors.Is(err, frankenphp.RequestContextCreationError):
+		return PHPErrorRequest
+	default:
+		return PHPErrorExecution
+	}
+}
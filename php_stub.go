@@ -0,0 +1,34 @@
+//go:build nofrankenphp
+
+package frango
+
+import (
+	"errors"
+	"net/http"
+)
+
+// errPHPUnavailable is returned by every PHP operation in builds without FrankenPHP
+var errPHPUnavailable = errors.New("frango was built without FrankenPHP (nofrankenphp build tag)")
+
+// startPHP fails: PHP can't run without FrankenPHP
+func startPHP(workers map[string]int, numThreads int) error {
+	return errPHPUnavailable
+}
+
+// stopPHP does nothing without FrankenPHP
+func stopPHP() {}
+
+// newPHPRequest fails: PHP can't run without FrankenPHP
+func newPHPRequest(r *http.Request, documentRoot string, env map[string]string) (*http.Request, error) {
+	return nil, errPHPUnavailable
+}
+
+// servePHPRequest fails: PHP can't run without FrankenPHP
+func servePHPRequest(w http.ResponseWriter, r *http.Request) error {
+	return errPHPUnavailable
+}
+
+// phpErrorCategory reports every error as unavailable: PHP can't run without FrankenPHP
+func phpErrorCategory(err error) PHPErrorCategory {
+	return PHPErrorUnavailable
+}
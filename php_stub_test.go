@@ -0,0 +1,806 @@
+//go:build nofrankenphp
+
+package frango
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// statusRouted marks responses that reached the point of running PHP. Without
+// FrankenPHP every script fails there, and the routing test instance's error
+// handler answers with this status and the route it was given.
+const statusRouted = http.StatusTeapot
+
+// newRoutingInstance builds a test instance whose requests go through all of frango's
+// routing and environment setup, stopping where PHP would run. The X-Routed-* headers
+// of a statusRouted response report the script, pattern and PATH_INFO.
+func newRoutingInstance(t *testing.T, files map[string]string, opts ...Option) *Middleware {
+	t.Helper()
+
+	var m *Middleware
+	var cleanup func()
+	reportRoute := WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err *PHPError) {
+		if !errors.Is(err, errPHPUnavailable) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		route, _ := RouteFromContext(r)
+		pathInfo, _ := r.Context().Value(pathInfoKey{}).(string)
+		script, _ := filepath.Rel(m.sourceDir, err.ScriptPath)
+		w.Header().Set("X-Routed-Script", filepath.ToSlash(script))
+		w.Header().Set("X-Routed-Pattern", route.Pattern)
+		w.Header().Set("X-Routed-Path-Info", pathInfo)
+		w.WriteHeader(statusRouted)
+	})
+
+	m, cleanup = NewTestInstance(files, append([]Option{quietLogger(), reportRoute}, opts...)...)
+
+	// The stub can't start PHP, so skip initialization; reset it so Shutdown doesn't
+	// release a runtime that was never acquired
+	m.initialized = true
+	t.Cleanup(func() {
+		m.initialized = false
+		cleanup()
+	})
+	return m
+}
+
+// routedScript returns the script path relative to the source directory that the
+// request was routed to, or "" if it never reached PHP
+func routedScript(recorder *httptest.ResponseRecorder) string {
+	if recorder.Code != statusRouted {
+		return ""
+	}
+	return recorder.Header().Get("X-Routed-Script")
+}
+
+func TestStubCannotStartPHP(t *testing.T) {
+	if _, err := acquirePHP(nil, 0, nil); !errors.Is(err, errPHPUnavailable) {
+		t.Fatalf("acquirePHP() error = %v, want errPHPUnavailable", err)
+	}
+	if phpRuntime.users != 0 {
+		t.Errorf("a failed start was counted as a user: %d", phpRuntime.users)
+	}
+
+	m, cleanup := NewTestInstance(map[string]string{"index.php": "<?php"}, quietLogger())
+	defer cleanup()
+
+	recorder := serve(m, http.MethodGet, "/")
+	if recorder.Code != http.StatusInternalServerError || !strings.Contains(recorder.Body.String(), "PHP initialization error") {
+		t.Errorf("GET / = %d %q, want a PHP initialization error", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRouting(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"index.php":      "<?php",
+		"about.php":      "<?php",
+		"form.php":       "<?php",
+		"form_post.php":  "<?php",
+		"docs/index.php": "<?php",
+		"docs/guide.php": "<?php",
+		"api/router.php": "<?php",
+		"style.css":      "body {}",
+	})
+	if err := m.HandleDir("/", m.sourceDir); err != nil {
+		t.Fatal(err)
+	}
+	m.Handle("POST /form", filepath.Join(m.sourceDir, "form_post.php"))
+	m.HandlePHP("/api/", "api/router.php")
+
+	tests := []struct {
+		method   string
+		target   string
+		script   string
+		pathInfo string
+	}{
+		{http.MethodGet, "/", "index.php", ""},
+		{http.MethodGet, "/about", "about.php", ""},
+		{http.MethodGet, "/about.php", "about.php", ""},
+		{http.MethodGet, "/about?x=1", "about.php", ""},
+		{http.MethodGet, "/docs/", "docs/index.php", ""},
+		{http.MethodGet, "/docs", "docs/index.php", ""},
+		{http.MethodGet, "/docs/guide", "docs/guide.php", ""},
+		{http.MethodGet, "/form", "form.php", ""},
+		{http.MethodPost, "/form", "form_post.php", ""},
+		{http.MethodGet, "/api/users/42", "api/router.php", "/users/42"},
+	}
+	for _, tt := range tests {
+		recorder := serve(m, tt.method, tt.target)
+		if script := routedScript(recorder); script != tt.script {
+			t.Errorf("%s %s routed to %q (status %d), want %q", tt.method, tt.target, script, recorder.Code, tt.script)
+			continue
+		}
+		if pathInfo := recorder.Header().Get("X-Routed-Path-Info"); pathInfo != tt.pathInfo {
+			t.Errorf("%s %s PATH_INFO = %q, want %q", tt.method, tt.target, pathInfo, tt.pathInfo)
+		}
+	}
+
+	if recorder := serve(m, http.MethodGet, "/style.css"); recorder.Code != http.StatusOK || recorder.Body.String() != "body {}" {
+		t.Errorf("GET /style.css = %d %q, want the static file", recorder.Code, recorder.Body.String())
+	}
+	for _, target := range []string{"/missing", "/docs/missing"} {
+		if recorder := serve(m, http.MethodGet, target); recorder.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, recorder.Code)
+		}
+	}
+}
+
+func TestWrapPassesUnroutedRequests(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{"about.php": "<?php"})
+	m.HandlePHP("/about", "about.php")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("next"))
+	})
+	handler := m.Wrap(next)
+
+	if recorder := serve(handler, http.MethodGet, "/about"); routedScript(recorder) != "about.php" {
+		t.Errorf("GET /about wasn't routed to PHP (status %d)", recorder.Code)
+	}
+	if recorder := serve(handler, http.MethodGet, "/elsewhere"); recorder.Body.String() != "next" {
+		t.Errorf("GET /elsewhere = %q, want it passed to the next handler", recorder.Body.String())
+	}
+}
+
+func TestDirectoryTraversalStaysInSourceDir(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"public/readme.txt": "hi",
+		".env":              "SECRET=1",
+	}, WithDirectoryListing(true), WithBlockedPaths("vendor/"))
+
+	// Raw paths reach routing unchanged with the default PathPassthrough policy
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.URL.Path = "/../../../../../../etc/"
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, r)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("GET /../../etc/ = %d, want 404", recorder.Code)
+	}
+
+	listing := serve(m, http.MethodGet, "/").Body.String()
+	if !strings.Contains(listing, "public/") {
+		t.Errorf("listing misses public/:\n%s", listing)
+	}
+	if strings.Contains(listing, ".env") {
+		t.Errorf("listing shows a blocked file:\n%s", listing)
+	}
+}
+
+func TestListRoutes(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{"users.php": "<?php", "create.php": "<?php"})
+	m.HandlePHP("/users", "users.php")
+	m.Handle("POST /users", filepath.Join(m.sourceDir, "create.php"))
+
+	routes := m.ListRoutes()
+	if len(routes) != 2 {
+		t.Fatalf("ListRoutes() returned %d routes, want 2: %v", len(routes), routes)
+	}
+	if routes[0].Method != "" || routes[0].Pattern != "/users" || routes[1].Method != http.MethodPost || routes[1].Pattern != "/users" {
+		t.Errorf("ListRoutes() = %+v", routes)
+	}
+}
+
+func TestRewriteServesTargetScript(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{"new-page.php": "<?php"},
+		WithRewrites([]RewriteRule{
+			{Pattern: `^/old-page$`, Target: "/new-page.php"},
+			{Pattern: `^/moved$`, Target: "/new-page", Redirect: http.StatusMovedPermanently},
+		}),
+	)
+
+	recorder := serve(m, http.MethodGet, "/old-page")
+	if script := routedScript(recorder); script != "new-page.php" {
+		t.Errorf("GET /old-page routed to %q (status %d), want new-page.php", script, recorder.Code)
+	}
+	if location := recorder.Header().Get("Location"); location != "" {
+		t.Errorf("internal rewrite redirected the client to %s", location)
+	}
+
+	recorder = serve(m, http.MethodGet, "/moved")
+	if recorder.Code != http.StatusMovedPermanently || recorder.Header().Get("Location") != "/new-page" {
+		t.Errorf("GET /moved = %d to %q, want 301 to /new-page", recorder.Code, recorder.Header().Get("Location"))
+	}
+}
+
+func TestOverlongURLIsRejected(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{"search.php": "<?php"}, WithMaxURLLength(64))
+	m.HandlePHP("/search", "search.php")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s was passed to the next handler", r.URL.Path)
+	})
+	handler := m.Wrap(next)
+
+	recorder := serve(handler, http.MethodGet, "/search?q="+strings.Repeat("x", 100))
+	if recorder.Code != http.StatusRequestURITooLong {
+		t.Errorf("over-length URL = %d, want 414", recorder.Code)
+	}
+	recorder = serve(handler, http.MethodGet, "/"+strings.Repeat("a", 100))
+	if recorder.Code != http.StatusRequestURITooLong {
+		t.Errorf("over-length unrouted URL = %d, want 414", recorder.Code)
+	}
+	if recorder := serve(handler, http.MethodGet, "/search?q=frango"); routedScript(recorder) != "search.php" {
+		t.Errorf("GET /search?q=frango wasn't routed to PHP (status %d)", recorder.Code)
+	}
+}
+
+func TestBlockedPathsAreNotServed(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"index.php":            "<?php",
+		".env":                 "SECRET=1",
+		"composer.json":        "{}",
+		".git/config":          "[core]",
+		"vendor/autoload.php":  "<?php",
+		"vendor/lib/README.md": "docs",
+		"style.css":            "body {}",
+	}, WithBlockedPaths("vendor/"))
+	if err := m.HandleDir("/", m.sourceDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/.env", "/composer.json", "/.git/config", "/vendor/autoload.php", "/vendor/autoload", "/vendor/lib/README.md", "/VENDOR/autoload.php"} {
+		if recorder := serve(m, http.MethodGet, target); recorder.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, recorder.Code)
+		}
+	}
+
+	if recorder := serve(m, http.MethodGet, "/"); routedScript(recorder) != "index.php" {
+		t.Errorf("GET / wasn't routed to index.php (status %d)", recorder.Code)
+	}
+	if recorder := serve(m, http.MethodGet, "/style.css"); recorder.Code != http.StatusOK {
+		t.Errorf("GET /style.css = %d, want 200", recorder.Code)
+	}
+}
+
+func TestManyQueryParamsAreCapped(t *testing.T) {
+	var env map[string]string
+	m := newRoutingInstance(t, map[string]string{"search.php": "<?php"},
+		WithMaxEnvVars(200, 0),
+		WithRequestPreparer(func(r *http.Request, phpEnv map[string]string) {
+			env = phpEnv
+		}),
+	)
+	m.HandlePHP("/search", "search.php")
+
+	query := make([]string, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		query = append(query, fmt.Sprintf("p%d=%d", i, i))
+	}
+	recorder := serve(m, http.MethodGet, "/search?"+strings.Join(query, "&"))
+	if routedScript(recorder) != "search.php" {
+		t.Fatalf("GET /search wasn't routed to PHP (status %d)", recorder.Code)
+	}
+
+	if len(env) > 200 {
+		t.Errorf("PHP got %d environment variables, want at most 200", len(env))
+	}
+	for _, key := range []string{"REQUEST_METHOD", "REQUEST_URI", "SCRIPT_NAME", "DOCUMENT_ROOT", "HTTP_HOST"} {
+		if env[key] == "" {
+			t.Errorf("%s was dropped", key)
+		}
+	}
+	if env["QUERY_STRING"] != strings.Join(query, "&") {
+		t.Error("QUERY_STRING doesn't hold the full query")
+	}
+}
+
+func TestInstancesShareRuntimeUntilLastShutdown(t *testing.T) {
+	// The stub can't start PHP, so act as a host that already did; instances then join it
+	phpRuntime.mutex.Lock()
+	if phpRuntime.users != 0 {
+		phpRuntime.mutex.Unlock()
+		t.Skip("another test left the PHP runtime running")
+	}
+	phpRuntime.users = 1
+	phpRuntime.mutex.Unlock()
+
+	first, cleanupFirst := NewTestInstance(map[string]string{"index.php": "<?php"}, quietLogger())
+	second, cleanupSecond := NewTestInstance(map[string]string{"index.php": "<?php"}, quietLogger())
+	for _, m := range []*Middleware{first, second} {
+		if err := m.ensureInitialized(context.Background()); err != nil {
+			t.Fatalf("joining the running runtime: %v", err)
+		}
+	}
+	if phpRuntime.users != 3 {
+		t.Fatalf("runtime has %d users, want 3", phpRuntime.users)
+	}
+
+	// The thread count is fixed when PHP starts, a joining instance can't change it
+	threads, cleanupThreads := NewTestInstance(map[string]string{"index.php": "<?php"}, quietLogger(), WithNumThreads(4))
+	defer cleanupThreads()
+	if err := threads.ensureInitialized(context.Background()); err == nil {
+		t.Error("an instance joining the runtime set the thread count")
+	}
+
+	// Shutting down one instance leaves PHP and its helper files to the others
+	cleanupFirst()
+	if phpRuntime.users != 2 {
+		t.Errorf("runtime has %d users after the first shutdown, want 2", phpRuntime.users)
+	}
+	if _, err := os.Stat(first.tempDir); err != nil {
+		t.Errorf("the first instance's temp dir was removed while PHP still runs: %v", err)
+	}
+	if !second.initialized {
+		t.Error("the second instance lost the runtime when the first shut down")
+	}
+
+	cleanupSecond()
+	releasePHP(t.TempDir())
+	if phpRuntime.users != 0 {
+		t.Errorf("runtime has %d users after every shutdown, want 0", phpRuntime.users)
+	}
+	for _, m := range []*Middleware{first, second} {
+		if _, err := os.Stat(m.tempDir); !os.IsNotExist(err) {
+			t.Errorf("temp dir %s survived the runtime: %v", m.tempDir, err)
+		}
+	}
+}
+
+func TestJoiningInstanceMustMatchIniSettings(t *testing.T) {
+	// Act as a host that already started PHP with WithDisabledFunctions
+	phpRuntime.mutex.Lock()
+	if phpRuntime.users != 0 {
+		phpRuntime.mutex.Unlock()
+		t.Skip("another test left the PHP runtime running")
+	}
+	phpRuntime.users = 1
+	phpRuntime.ini = map[string]string{"disable_functions": "exec,system"}
+	phpRuntime.mutex.Unlock()
+	defer releasePHP(t.TempDir())
+
+	tests := []struct {
+		name string
+		opts []Option
+		ok   bool
+	}{
+		{"same directives", []Option{WithDisabledFunctions([]string{"exec", "system"})}, true},
+		{"no sandbox", nil, false},
+		{"other functions", []Option{WithDisabledFunctions([]string{"exec"})}, false},
+		{"extra directive", []Option{WithDisabledFunctions([]string{"exec", "system"}), WithPHPIni(map[string]string{"memory_limit": "64M"})}, false},
+		{"open_basedir", []Option{WithDisabledFunctions([]string{"exec", "system"}), WithOpenBasedir()}, false},
+	}
+	for _, tt := range tests {
+		m, cleanup := NewTestInstance(map[string]string{"index.php": "<?php"}, append([]Option{quietLogger()}, tt.opts...)...)
+		err := m.ensureInitialized(context.Background())
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("%s: joining the runtime returned %v, want success %v", tt.name, err, tt.ok)
+		}
+		cleanup()
+	}
+}
+
+func TestRequestIDReachesPHP(t *testing.T) {
+	var env map[string]string
+	m := newRoutingInstance(t, map[string]string{"index.php": "<?php"},
+		WithRequestID("X-Correlation-Id"),
+		WithRequestPreparer(func(r *http.Request, phpEnv map[string]string) {
+			env = phpEnv
+		}),
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/index.php", nil)
+	r.Header.Set("X-Correlation-Id", "abc123")
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, r)
+
+	if routedScript(recorder) != "index.php" {
+		t.Fatalf("GET /index.php wasn't routed to PHP (status %d)", recorder.Code)
+	}
+	if id := recorder.Header().Get("X-Correlation-Id"); id != "abc123" {
+		t.Errorf("response header = %q, want abc123 echoed back", id)
+	}
+	for _, key := range []string{"FRANGO_REQUEST_ID", "frango_REQUEST_ID", "HTTP_X_REQUEST_ID"} {
+		if env[key] != "abc123" {
+			t.Errorf("%s = %q, want abc123", key, env[key])
+		}
+	}
+
+	// A generated ID is exposed the same way
+	recorder = serve(m, http.MethodGet, "/index.php")
+	if id := recorder.Header().Get("X-Correlation-Id"); id == "" || env["FRANGO_REQUEST_ID"] != id || env["HTTP_X_REQUEST_ID"] != id {
+		t.Errorf("generated ID %q, PHP saw %q and %q", id, env["FRANGO_REQUEST_ID"], env["HTTP_X_REQUEST_ID"])
+	}
+}
+
+func TestServeDirectoryServesOnlyItsTree(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"pages/index.php":      "<?php",
+		"pages/about.php":      "<?php",
+		"pages/docs/guide.php": "<?php",
+		"pages/style.css":      "body {}",
+		"admin.php":            "<?php",
+		"secret.php":           "<?php",
+	})
+	m.HandlePHP("/pages/admin", "admin.php")
+
+	pages, err := m.ServeDirectory(nil, "pages", "/pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for target, script := range map[string]string{
+		"/pages/":                "pages/index.php",
+		"/pages":                 "pages/index.php",
+		"/pages/about":           "pages/about.php",
+		"/pages/about.php":       "pages/about.php",
+		"/pages/docs/guide":      "pages/docs/guide.php",
+		"/pages//docs/guide.php": "pages/docs/guide.php",
+	} {
+		if routed := routedScript(serve(pages, http.MethodGet, target)); routed != script {
+			t.Errorf("GET %s routed to %q, want %q", target, routed, script)
+		}
+	}
+
+	for _, target := range []string{"/pages/admin", "/secret.php", "/secret", "/pages/missing", "/pages/style.css", "/pages/../secret.php"} {
+		if recorder := serve(pages, http.MethodGet, target); recorder.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d (script %q), want 404 outside the tree", target, recorder.Code, routedScript(recorder))
+		}
+	}
+}
+
+func TestMetadataProviderAnswersWithoutPHP(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	length := int64(1234)
+	calls := 0
+	m := newRoutingInstance(t, map[string]string{"article.php": "<?php"},
+		WithMetadataProvider("article.php", func(r *http.Request) ResponseMeta {
+			calls++
+			return ResponseMeta{ETag: `"v42"`, LastModified: modified, ContentType: "text/html", ContentLength: &length}
+		}))
+	m.HandlePHP("/article", "article.php")
+
+	request := func(method string, header, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/article", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, r)
+		return recorder
+	}
+
+	head := request(http.MethodHead, "", "")
+	if head.Code != http.StatusOK || head.Body.Len() != 0 {
+		t.Errorf("HEAD = %d with %d body bytes, want 200 headers only without PHP", head.Code, head.Body.Len())
+	}
+	for header, want := range map[string]string{
+		"ETag":           `"v42"`,
+		"Last-Modified":  modified.Format(http.TimeFormat),
+		"Content-Type":   "text/html",
+		"Content-Length": "1234",
+	} {
+		if got := head.Header().Get(header); got != want {
+			t.Errorf("HEAD %s = %q, want %q", header, got, want)
+		}
+	}
+
+	for _, tt := range []struct{ header, value string }{
+		{"If-None-Match", `"v42"`},
+		{"If-None-Match", `"v41", W/"v42"`},
+		{"If-None-Match", "*"},
+		{"If-Modified-Since", modified.Format(http.TimeFormat)},
+	} {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			if recorder := request(method, tt.header, tt.value); recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+				t.Errorf("%s with %s: %s = %d, want 304 without PHP", method, tt.header, tt.value, recorder.Code)
+			}
+		}
+	}
+
+	// A stale validator needs the body, so PHP runs with the validators already set
+	stale := request(http.MethodGet, "If-None-Match", `"v41"`)
+	if stale.Code != statusRouted || stale.Header().Get("ETag") != `"v42"` {
+		t.Errorf("stale GET = %d with ETag %q, want PHP to run with the current ETag", stale.Code, stale.Header().Get("ETag"))
+	}
+	if plain := request(http.MethodGet, "", ""); plain.Code != statusRouted {
+		t.Errorf("GET = %d, want PHP to run", plain.Code)
+	}
+
+	if calls != 11 {
+		t.Errorf("provider called %d times, want once per request", calls)
+	}
+}
+
+func TestEventsForRequestCreatingEnvironment(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{"page.php": "<?php"})
+	events := m.Events()
+
+	if recorder := serve(m, http.MethodGet, "/page.php"); recorder.Code != statusRouted {
+		t.Fatalf("GET /page.php = %d, want it routed", recorder.Code)
+	}
+
+	scriptPath := filepath.Join(m.sourceDir, "page.php")
+	want := []FrangoEvent{
+		{Type: EventRequestStarted, Path: "/page.php", ScriptPath: scriptPath},
+		{Type: EventEnvCreated, Path: "/page.php", ScriptPath: scriptPath},
+		{Type: EventPHPError, Path: "/page.php", ScriptPath: scriptPath},
+		{Type: EventRequestCompleted, Path: "/page.php", ScriptPath: scriptPath, Status: statusRouted},
+	}
+	for i, expected := range want {
+		var event FrangoEvent
+		select {
+		case event = <-events:
+		default:
+			t.Fatalf("got %d events, want %d", i, len(want))
+		}
+		if event.Type != expected.Type || event.Path != expected.Path || event.ScriptPath != expected.ScriptPath || event.Status != expected.Status {
+			t.Errorf("event %d = %s %s %s %d, want %s %s %s %d", i,
+				event.Type, event.Path, event.ScriptPath, event.Status,
+				expected.Type, expected.Path, expected.ScriptPath, expected.Status)
+		}
+		if event.Time.IsZero() {
+			t.Errorf("event %d (%s) has no time", i, event.Type)
+		}
+		switch event.Type {
+		case EventEnvCreated:
+			if event.EnvID == "" {
+				t.Error("env_created has no environment ID")
+			}
+		case EventPHPError:
+			if !errors.Is(event.Err, errPHPUnavailable) {
+				t.Errorf("php_error Err = %v, want the failure", event.Err)
+			}
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected extra event %s", event.Type)
+	default:
+	}
+
+	// The second request reuses the environment
+	serve(m, http.MethodGet, "/page.php")
+	for len(events) > 0 {
+		if event := <-events; event.Type == EventEnvCreated {
+			t.Error("second request created another environment")
+		}
+	}
+}
+
+func TestAPIVersionsRouteAndWrapSeparately(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"v1/users.php": "<?php",
+		"v2/users.php": "<?php",
+	})
+
+	var ran []string
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ran = append(ran, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	v1 := m.APIVersion("/v1", WithVersionMiddleware(tag("v1")))
+	v1.Handle("GET /users", "users.php")
+	v2 := m.APIVersion("/v2", WithVersionMiddleware(tag("v2-outer"), tag("v2-inner")))
+	v2.Handle("GET /users", "users.php")
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", v1)
+	mux.Handle("/v2/", v2)
+
+	for _, tt := range []struct {
+		target string
+		script string
+		ran    []string
+	}{
+		{"/v1/users", "v1/users.php", []string{"v1"}},
+		{"/v2/users", "v2/users.php", []string{"v2-outer", "v2-inner"}},
+	} {
+		ran = nil
+		if script := routedScript(serve(mux, http.MethodGet, tt.target)); script != tt.script {
+			t.Errorf("GET %s routed to %q, want %q", tt.target, script, tt.script)
+		}
+		if fmt.Sprint(ran) != fmt.Sprint(tt.ran) {
+			t.Errorf("GET %s ran middleware %v, want %v", tt.target, ran, tt.ran)
+		}
+	}
+}
+
+func TestHandleEmbedDirMountsNestedTree(t *testing.T) {
+	m := newRoutingInstance(t, nil)
+	site := fstest.MapFS{
+		"site/index.php":            {Data: []byte("<?php")},
+		"site/blog/index.php":       {Data: []byte("<?php")},
+		"site/blog/posts/hello.php": {Data: []byte("<?php")},
+		"site/lib/helpers.inc":      {Data: []byte("<?php function h() {}")},
+		"site/assets/app.css":       {Data: []byte("body {}")},
+		"other/skipped.php":         {Data: []byte("<?php")},
+	}
+
+	if err := m.HandleEmbedDir("/app", site, "site"); err != nil {
+		t.Fatal(err)
+	}
+
+	for target, script := range map[string]string{
+		"/app/":                     "site/index.php",
+		"/app/blog/":                "site/blog/index.php",
+		"/app/blog":                 "site/blog/index.php",
+		"/app/blog/posts/hello":     "site/blog/posts/hello.php",
+		"/app/blog/posts/hello.php": "site/blog/posts/hello.php",
+	} {
+		if routed := routedScript(serve(m, http.MethodGet, target)); routed != script {
+			t.Errorf("GET %s routed to %q, want %q", target, routed, script)
+		}
+	}
+
+	// Non-PHP files are extracted next to the scripts so includes resolve
+	for name, content := range map[string]string{
+		"site/lib/helpers.inc": "<?php function h() {}",
+		"site/assets/app.css":  "body {}",
+	} {
+		if data, err := os.ReadFile(filepath.Join(m.sourceDir, name)); err != nil || string(data) != content {
+			t.Errorf("%s extracted as %q (%v), want %q", name, data, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(m.sourceDir, "other")); !os.IsNotExist(err) {
+		t.Errorf("files outside the root were extracted: %v", err)
+	}
+
+	if err := m.HandleEmbedDir("/missing", site, "nope"); err == nil {
+		t.Error("HandleEmbedDir with a missing root succeeded")
+	}
+}
+
+func TestVariantSelection(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"article.php":       "<?php",
+		"article_amp.php":   "<?php",
+		"article_print.php": "<?php",
+		"article_lite.php":  "<?php",
+	}, WithVariants("article.php", map[string]string{
+		"?amp=1":          "article_amp.php",
+		"?print":          "article_print.php",
+		"X-Variant: lite": "article_lite.php",
+	}))
+	m.HandlePHP("/article", "article.php")
+
+	tests := []struct {
+		target  string
+		variant string
+		script  string
+		links   []string
+	}{
+		{"/article", "", "article.php", []string{`</article?amp=1>; rel="alternate"`, `</article?print=>; rel="alternate"`}},
+		{"/article?amp=1", "", "article_amp.php", []string{`</article?amp=1&print=>; rel="alternate"`, `</article>; rel="alternate"`}},
+		{"/article?amp=2", "", "article.php", []string{`</article?amp=1>; rel="alternate"`, `</article?amp=2&print=>; rel="alternate"`}},
+		{"/article?print", "", "article_print.php", []string{`</article?amp=1&print=>; rel="alternate"`, `</article>; rel="alternate"`}},
+		{"/article", "LITE", "article_lite.php", []string{`</article?amp=1>; rel="alternate"`, `</article?print=>; rel="alternate"`}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.variant != "" {
+			r.Header.Set("X-Variant", tt.variant)
+		}
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, r)
+
+		if script := routedScript(recorder); script != tt.script {
+			t.Errorf("GET %s (X-Variant %q) routed to %q, want %q", tt.target, tt.variant, script, tt.script)
+		}
+		if links := recorder.Header().Values("Link"); fmt.Sprint(links) != fmt.Sprint(tt.links) {
+			t.Errorf("GET %s (X-Variant %q) Link = %q, want %q", tt.target, tt.variant, links, tt.links)
+		}
+		if vary := recorder.Header().Values("Vary"); !slices.Contains(vary, "X-Variant") {
+			t.Errorf("GET %s Vary = %q, want X-Variant", tt.target, vary)
+		}
+	}
+}
+
+func TestStrictRenderDataFailsRequest(t *testing.T) {
+	render := func(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+		return map[string]interface{}{"title": "Hello", "updates": make(chan int)}
+	}
+
+	strict := newRoutingInstance(t, map[string]string{"page.php": "<?php"}, WithDevelopmentMode(true), WithStrictRenderData(true))
+	strict.HandleRender("/strict-render", "page.php", render)
+	recorder := serve(strict, http.MethodGet, "/strict-render")
+	if recorder.Code != http.StatusInternalServerError || !strings.Contains(recorder.Body.String(), `"updates"`) {
+		t.Errorf("strict render = %d %q, want a 500 naming the key", recorder.Code, recorder.Body.String())
+	}
+
+	var env map[string]string
+	lenient := newRoutingInstance(t, map[string]string{"page.php": "<?php"}, WithDevelopmentMode(true),
+		WithRequestPreparer(func(r *http.Request, phpEnv map[string]string) {
+			env = phpEnv
+		}))
+	lenient.HandleRender("/lenient-render", "page.php", render)
+	if recorder := serve(lenient, http.MethodGet, "/lenient-render"); recorder.Code != statusRouted {
+		t.Fatalf("lenient render = %d, want PHP to run", recorder.Code)
+	}
+	if _, found := env["frango_VAR_updates"]; found || env["frango_VAR_title"] != `"Hello"` {
+		t.Errorf("lenient render passed title %q and updates %t, want only the title", env["frango_VAR_title"], found)
+	}
+}
+
+func TestRegisterManifest(t *testing.T) {
+	m := newRoutingInstance(t, map[string]string{
+		"routes.php":       "<?php",
+		"users/index.php":  "<?php",
+		"users/create.php": "<?php",
+		"about.php":        "<?php",
+	})
+
+	routes, err := m.registerManifest("routes.php", []byte(`{"routes": [
+		{"method": "get", "pattern": "/users", "script": "users/index.php"},
+		{"method": "POST", "pattern": "/users", "script": "users/create.php"},
+		{"pattern": "about", "script": "about.php"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []RouteInfo{
+		{Method: "GET", Pattern: "/users", ScriptPath: filepath.Join(m.sourceDir, "users/index.php")},
+		{Method: "POST", Pattern: "/users", ScriptPath: filepath.Join(m.sourceDir, "users/create.php")},
+		{Pattern: "/about", ScriptPath: filepath.Join(m.sourceDir, "about.php")},
+	}
+	if fmt.Sprint(routes) != fmt.Sprint(want) {
+		t.Errorf("routes = %+v, want %+v", routes, want)
+	}
+
+	for _, tt := range []struct{ method, target, script string }{
+		{http.MethodGet, "/users", "users/index.php"},
+		{http.MethodPost, "/users", "users/create.php"},
+		{http.MethodGet, "/about", "about.php"},
+	} {
+		if script := routedScript(serve(m, tt.method, tt.target)); script != tt.script {
+			t.Errorf("%s %s routed to %q, want %q", tt.method, tt.target, script, tt.script)
+		}
+	}
+
+	for name, manifest := range map[string]string{
+		"invalid JSON":    `Warning: something went wrong`,
+		"missing script":  `{"routes": [{"pattern": "/broken"}]}`,
+		"missing pattern": `{"routes": [{"script": "about.php"}]}`,
+	} {
+		if _, err := m.registerManifest("routes.php", []byte(manifest)); err == nil {
+			t.Errorf("%s: registerManifest succeeded", name)
+		}
+	}
+	if _, found := m.routes["/broken"]; found {
+		t.Error("an invalid manifest registered routes")
+	}
+}
+
+func TestServerTimingHeader(t *testing.T) {
+	var env map[string]string
+	preparer := WithRequestPreparer(func(r *http.Request, phpEnv map[string]string) {
+		env = phpEnv
+	})
+
+	m := newRoutingInstance(t, map[string]string{"page.php": "<?php"}, WithServerTiming(true), preparer)
+	recorder := serve(m, http.MethodGet, "/page.php")
+	timing := recorder.Header().Values("Server-Timing")
+	if len(timing) != 1 || !regexp.MustCompile(`^frango-env;dur=\d+\.\d{2}$`).MatchString(timing[0]) {
+		t.Errorf("Server-Timing = %q, want a single frango-env phase in milliseconds", timing)
+	}
+	if env["frango_SERVER_TIMING"] != "1" {
+		t.Error("PHP wasn't told to report its own phases")
+	}
+
+	env = nil
+	m = newRoutingInstance(t, map[string]string{"page.php": "<?php"}, preparer)
+	if recorder := serve(m, http.MethodGet, "/page.php"); recorder.Header().Get("Server-Timing") != "" {
+		t.Errorf("Server-Timing = %q without WithServerTiming", recorder.Header().Get("Server-Timing"))
+	}
+	if _, found := env["frango_SERVER_TIMING"]; found {
+		t.Error("PHP was told to report phases without WithServerTiming")
+	}
+}
@@ -0,0 +1,82 @@
+package frango
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// PHPErrorCategory classifies why a PHP script couldn't be served
+type PHPErrorCategory string
+
+const (
+	// PHPErrorNotFound means the script file doesn't exist
+	PHPErrorNotFound PHPErrorCategory = "not_found"
+	// PHPErrorUnavailable means the PHP runtime isn't running
+	PHPErrorUnavailable PHPErrorCategory = "unavailable"
+	// PHPErrorRequest means FrankenPHP rejected or couldn't prepare the request
+	PHPErrorRequest PHPErrorCategory = "request"
+	// PHPErrorExecution covers any other failure while running the script
+	PHPErrorExecution PHPErrorCategory = "execution"
+	// PHPErrorScript means the script printed a PHP error or warning (WithStrictErrors)
+	PHPErrorScript PHPErrorCategory = "script"
+)
+
+// PHPError describes a failed PHP execution
+type PHPError struct {
+	// ScriptPath is the absolute path of the source script
+	ScriptPath string
+	// Category classifies the failure
+	Category PHPErrorCategory
+	// Err is the underlying FrankenPHP error
+	Err error
+}
+
+// Error implements the error interface
+func (e *PHPError) Error() string {
+	return fmt.Sprintf("PHP %s error in %s: %v", e.Category, e.ScriptPath, e.Err)
+}
+
+// Unwrap returns the underlying FrankenPHP error
+func (e *PHPError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler renders a response for a failed PHP execution
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err *PHPError)
+
+// WithErrorHandler sets a handler called instead of the default 500 response when a
+// PHP script fails, e.g. to render a branded error page or report to an error tracker.
+// Parse errors and fatals inside a running script are reported by PHP in its own output
+// and don't reach the handler.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(m *Middleware) {
+		m.errorHandler = handler
+	}
+}
+
+// newPHPError wraps a FrankenPHP error with the script path and category
+func newPHPError(scriptPath string, err error) *PHPError {
+	category := phpErrorCategory(err)
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) {
+		category = PHPErrorScript
+	} else if _, statErr := os.Stat(scriptPath); os.IsNotExist(statErr) {
+		category = PHPErrorNotFound
+	}
+	return &PHPError{ScriptPath: scriptPath, Category: category, Err: err}
+}
+
+// phpError reports a failed PHP execution through the configured error handler
+func (m *Middleware) phpError(w http.ResponseWriter, r *http.Request, scriptPath string, err error) {
+	phpErr := newPHPError(scriptPath, err)
+	m.logger.Printf("Error executing PHP: %v", phpErr)
+	m.emit(FrangoEvent{Type: EventPHPError, Path: r.URL.Path, ScriptPath: scriptPath, Err: phpErr})
+
+	if m.errorHandler != nil {
+		m.errorHandler(w, r, phpErr)
+		return
+	}
+	http.Error(w, "PHP execution error: "+err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,82 @@
+package frango
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+// infoFileName is the name of the generated script reporting PHP's configuration
+const infoFileName = "_frango_info.php"
+
+// infoScript prints the running PHP's version, SAPI and extensions as JSON
+const infoScript = `<?php
+// Generated by frango - reports the PHP runtime for Middleware.Info
+header('Content-Type: application/json');
+echo json_encode([
+    'version' => PHP_VERSION,
+    'sapi' => PHP_SAPI,
+    'zts' => (bool) PHP_ZTS,
+    'extensions' => get_loaded_extensions(),
+]);
+`
+
+// PHPInfo describes the PHP runtime frango runs scripts with
+type PHPInfo struct {
+	// Version is the PHP version, e.g. "8.3.14"
+	Version string `json:"version"`
+	// SAPI is the server API PHP runs under ("frankenphp")
+	SAPI string `json:"sapi"`
+	// ZTS reports whether PHP is built thread-safe
+	ZTS bool `json:"zts"`
+	// Extensions lists the loaded extensions
+	Extensions []string `json:"extensions"`
+}
+
+// Info returns the PHP version, SAPI and loaded extensions, e.g. for health checks.
+// PHP is initialized if needed. The result is computed by running a small internal
+// script once and cached, since it can't change while the process runs.
+func (m *Middleware) Info() (PHPInfo, error) {
+	m.infoMutex.Lock()
+	defer m.infoMutex.Unlock()
+
+	if m.phpInfo != nil {
+		return *m.phpInfo, nil
+	}
+
+	if err := m.ensureInitialized(context.Background()); err != nil {
+		return PHPInfo{}, fmt.Errorf("error initializing PHP: %w", err)
+	}
+
+	// The script lives in the temp directory, outside the source tree, so it's never routable
+	if err := os.WriteFile(filepath.Join(m.tempDir, infoFileName), []byte(infoScript), 0644); err != nil {
+		return PHPInfo{}, fmt.Errorf("error writing info script: %w", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+infoFileName, nil)
+	phpReq, err := newPHPRequest(req, m.tempDir, map[string]string{})
+	if err != nil {
+		return PHPInfo{}, fmt.Errorf("error creating PHP request: %w", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	if err := servePHPRequest(recorder, phpReq); err != nil {
+		return PHPInfo{}, fmt.Errorf("error running info script: %w", err)
+	}
+	if recorder.Code != http.StatusOK {
+		return PHPInfo{}, fmt.Errorf("info script responded with status %d", recorder.Code)
+	}
+
+	var info PHPInfo
+	if err := json.Unmarshal(recorder.Body.Bytes(), &info); err != nil {
+		return PHPInfo{}, fmt.Errorf("error decoding PHP info: %w", err)
+	}
+
+	m.phpInfo = &info
+	m.logger.Printf("PHP %s (%s) with %d extensions", info.Version, info.SAPI, len(info.Extensions))
+	return info, nil
+}
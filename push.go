@@ -0,0 +1,73 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PushKey is the reserved render data key listing assets the page needs, e.g.
+// "__push": []string{"/css/dashboard.css", "/js/charts.js"}. They are sent as
+// Link preload headers before PHP runs and are not passed to the script.
+const PushKey = "__push"
+
+// preloadTypes maps asset extensions to the preload "as" destination browsers require
+var preloadTypes = map[string]string{
+	".css":   "style",
+	".js":    "script",
+	".mjs":   "script",
+	".woff":  "font",
+	".woff2": "font",
+	".ttf":   "font",
+	".otf":   "font",
+	".png":   "image",
+	".jpg":   "image",
+	".jpeg":  "image",
+	".gif":   "image",
+	".svg":   "image",
+	".webp":  "image",
+	".avif":  "image",
+}
+
+// addPushHints emits a Link preload header for each asset listed under PushKey in
+// render data, so HTTP/2 proxies and CDNs can push or early-hint them
+func (m *Middleware) addPushHints(w http.ResponseWriter, data map[string]interface{}) {
+	value, exists := data[PushKey]
+	if !exists {
+		return
+	}
+
+	var assets []string
+	switch v := value.(type) {
+	case string:
+		assets = []string{v}
+	case []string:
+		assets = v
+	case []interface{}:
+		for _, item := range v {
+			if asset, ok := item.(string); ok {
+				assets = append(assets, asset)
+			}
+		}
+	default:
+		m.logger.Printf("WARNING: Ignoring %s render data of type %T, expected a list of paths", PushKey, value)
+		return
+	}
+
+	for _, asset := range assets {
+		if asset == "" {
+			continue
+		}
+		link := fmt.Sprintf("<%s>; rel=preload", asset)
+		extension := strings.ToLower(path.Ext(strings.SplitN(asset, "?", 2)[0]))
+		if as, known := preloadTypes[extension]; known {
+			link += "; as=" + as
+			// Fonts are always fetched in CORS mode
+			if as == "font" {
+				link += "; crossorigin"
+			}
+		}
+		w.Header().Add("Link", link)
+	}
+}
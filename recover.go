@@ -0,0 +1,40 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// WithRecover catches panics raised on the Go side while serving a PHP route, such
+// as in a render function, logs them with the script path and stack, and answers
+// with 500 Internal Server Error instead of dropping the connection. Execute and
+// ExecuteTo return the panic as an error.
+func WithRecover(enabled bool) Option {
+	return func(m *Middleware) {
+		m.recoverPanics = enabled
+	}
+}
+
+// recoverPanic is deferred around PHP routes; it turns a panic into a logged 500
+func (m *Middleware) recoverPanic(w http.ResponseWriter, sourcePath string) {
+	if !m.recoverPanics {
+		return
+	}
+	if p := recover(); p != nil {
+		m.logger.Printf("Recovered from panic while serving %s: %v\n%s", sourcePath, p, debug.Stack())
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// recoverPanicError is deferred around script executions returning an error; it
+// turns a panic into that error
+func (m *Middleware) recoverPanicError(sourcePath string, err *error) {
+	if !m.recoverPanics {
+		return
+	}
+	if p := recover(); p != nil {
+		m.logger.Printf("Recovered from panic while executing %s: %v\n%s", sourcePath, p, debug.Stack())
+		*err = fmt.Errorf("panic while executing %s: %v", sourcePath, p)
+	}
+}
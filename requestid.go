@@ -0,0 +1,66 @@
+package frango
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// requestIDKey is the context key carrying the request ID
+type requestIDKey struct{}
+
+// WithRequestID gives every PHP request an ID for correlating Go and PHP logs. The ID
+// is read from the headerName request header (e.g. "X-Request-Id") or generated when
+// missing, echoed back in the same response header, and exposed to PHP as
+// $_SERVER['FRANGO_REQUEST_ID'] (also frango_REQUEST_ID, like the other frango_
+// variables) and $_SERVER['HTTP_X_REQUEST_ID'], whatever headerName is, as well as
+// through the headerName request header itself.
+func WithRequestID(headerName string) Option {
+	return func(m *Middleware) {
+		m.requestIDHeader = http.CanonicalHeaderKey(headerName)
+	}
+}
+
+// assignRequestID returns r carrying its request ID, generating one when the client
+// sent none, and sets the response header
+func (m *Middleware) assignRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	if m.requestIDHeader == "" {
+		return r
+	}
+
+	id := r.Header.Get(m.requestIDHeader)
+	generated := id == ""
+	if generated {
+		id = newRequestID()
+	}
+	w.Header().Set(m.requestIDHeader, id)
+
+	r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+	if generated {
+		// Copy the headers so PHP sees the ID without mutating the caller's request
+		r.Header = r.Header.Clone()
+		r.Header.Set(m.requestIDHeader, id)
+	}
+	return r
+}
+
+// addRequestIDEnv exposes a request's ID to PHP
+func addRequestIDEnv(env map[string]string, id string) {
+	env["FRANGO_REQUEST_ID"] = id
+	env["frango_REQUEST_ID"] = id
+	env["HTTP_X_REQUEST_ID"] = id
+}
+
+// requestID returns the ID assigned to a request, if any
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID generates a random 128-bit hex request ID
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,103 @@
+package frango
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// bufferedResponse captures a PHP response in memory so it can be inspected
+// or replayed before anything is sent to the client
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// newBufferedResponse creates an empty buffered response
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{
+		header: make(http.Header),
+	}
+}
+
+// Header returns the captured response headers
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader records the status code (only the first call counts)
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Write appends to the captured body
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// Flush is a no-op so PHP's flush() calls don't fail while buffering
+func (b *bufferedResponse) Flush() {}
+
+// statusCode returns the captured status, defaulting to 200
+func (b *bufferedResponse) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// writeTo replays the captured response onto a real ResponseWriter, merging its
+// headers into the ones already set on w (see mergeHeaders)
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	mergeHeaders(w.Header(), b.header)
+	w.WriteHeader(b.statusCode())
+	w.Write(b.body.Bytes())
+}
+
+// repeatableHeaders may be sent several times, so values already set on the outer
+// writer (e.g. cookies from a render function) are kept alongside the replayed ones
+var repeatableHeaders = map[string]bool{
+	"Set-Cookie":    true,
+	"Link":          true,
+	"Server-Timing": true,
+}
+
+// mergeHeaders copies a captured response's headers onto dst. Repeatable headers are
+// appended, Vary gains only the fields it doesn't list yet, and every other header
+// replaces the value on dst, so a Content-Type set before PHP ran isn't sent twice.
+func mergeHeaders(dst http.Header, src http.Header) {
+	for key, values := range src {
+		switch {
+		case repeatableHeaders[key]:
+			dst[key] = append(dst[key], values...)
+		case key == "Vary":
+			for _, value := range values {
+				for _, field := range strings.Split(value, ",") {
+					if field = strings.TrimSpace(field); field != "" && !varies(dst, field) {
+						dst.Add("Vary", field)
+					}
+				}
+			}
+		default:
+			dst[key] = append([]string(nil), values...)
+		}
+	}
+}
+
+// varies reports whether a Vary header already lists field
+func varies(header http.Header, field string) bool {
+	for _, value := range header.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), field) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package frango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// phpResponse writes what a PHP script typically sends, over headers frango set first
+func phpResponse(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Encoding, Accept")
+	w.Header().Add("Set-Cookie", "php=1")
+	w.Write([]byte(`{}`))
+}
+
+// outerRecorder returns a recorder with the headers frango sets before PHP runs
+func outerRecorder() *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Content-Type", "text/html; charset=utf-8")
+	recorder.Header().Add("Vary", "Origin")
+	recorder.Header().Add("Vary", "Accept-Encoding")
+	recorder.Header().Add("Set-Cookie", "render=1")
+	return recorder
+}
+
+// assertMergedHeaders checks the replayed headers weren't duplicated
+func assertMergedHeaders(t *testing.T, header http.Header) {
+	t.Helper()
+	if got := header.Values("Content-Type"); !reflect.DeepEqual(got, []string{"application/json"}) {
+		t.Errorf("Content-Type = %q, want PHP's only", got)
+	}
+	var vary []string
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			vary = append(vary, strings.TrimSpace(field))
+		}
+	}
+	if want := []string{"Origin", "Accept-Encoding", "Accept"}; !reflect.DeepEqual(vary, want) {
+		t.Errorf("Vary = %q, want %q", vary, want)
+	}
+	if got := header.Values("Set-Cookie"); !reflect.DeepEqual(got, []string{"render=1", "php=1"}) {
+		t.Errorf("Set-Cookie = %q, want both cookies", got)
+	}
+}
+
+func TestBufferedResponseDoesNotDuplicateHeaders(t *testing.T) {
+	buffered := newBufferedResponse()
+	phpResponse(buffered)
+
+	recorder := outerRecorder()
+	buffered.writeTo(recorder)
+
+	assertMergedHeaders(t, recorder.Header())
+	if recorder.Body.String() != `{}` {
+		t.Errorf("body = %q", recorder.Body.String())
+	}
+}
+
+func TestHeadResponseDoesNotDuplicateHeaders(t *testing.T) {
+	m := &Middleware{}
+	recorder := outerRecorder()
+	m.serveHead(recorder, httptest.NewRequest(http.MethodHead, "/", nil), phpResponse)
+
+	assertMergedHeaders(t, recorder.Header())
+	if recorder.Header().Get("Content-Length") != "2" || recorder.Body.Len() != 0 {
+		t.Errorf("HEAD sent Content-Length %q and %d body bytes, want 2 and none", recorder.Header().Get("Content-Length"), recorder.Body.Len())
+	}
+}
@@ -0,0 +1,33 @@
+package frango
+
+import "time"
+
+// WithEnvRetry retries environment setup up to attempts times, waiting backoff
+// (multiplied by the attempt number) between tries, to ride out transient
+// filesystem errors such as temp dir races before answering with a 500.
+func WithEnvRetry(attempts int, backoff time.Duration) Option {
+	return func(m *Middleware) {
+		m.envRetryAttempts = attempts
+		m.envRetryBackoff = backoff
+	}
+}
+
+// withEnvRetry runs an environment operation, retrying it as configured by WithEnvRetry
+func (m *Middleware) withEnvRetry(description string, operation func() error) error {
+	attempts := m.envRetryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = operation(); err == nil {
+			return nil
+		}
+		if attempt < attempts {
+			m.logger.Printf("Attempt %d/%d to %s failed, retrying: %v", attempt, attempts, description, err)
+			time.Sleep(m.envRetryBackoff * time.Duration(attempt))
+		}
+	}
+	return err
+}
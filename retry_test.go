@@ -0,0 +1,59 @@
+package frango
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// flakyOperation fails its first failures calls, then succeeds
+func flakyOperation(failures int) (func() error, *int) {
+	calls := 0
+	return func() error {
+		calls++
+		if calls <= failures {
+			return errors.New("transient failure")
+		}
+		return nil
+	}, &calls
+}
+
+func TestEnvRetryRecoversFromTransientErrors(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	WithEnvRetry(3, time.Millisecond)(m)
+
+	operation, calls := flakyOperation(2)
+	if err := m.withEnvRetry("create environment", operation); err != nil {
+		t.Fatalf("withEnvRetry() = %v, want success on the third attempt", err)
+	}
+	if *calls != 3 {
+		t.Errorf("operation ran %d times, want 3", *calls)
+	}
+}
+
+func TestEnvRetryGivesUp(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	WithEnvRetry(3, time.Millisecond)(m)
+
+	operation, calls := flakyOperation(10)
+	if err := m.withEnvRetry("create environment", operation); err == nil {
+		t.Fatal("withEnvRetry() succeeded with an operation that always fails")
+	}
+	if *calls != 3 {
+		t.Errorf("operation ran %d times, want 3", *calls)
+	}
+}
+
+func TestEnvRetryDisabledRunsOnce(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+
+	operation, calls := flakyOperation(1)
+	if err := m.withEnvRetry("create environment", operation); err == nil {
+		t.Fatal("withEnvRetry() retried without WithEnvRetry")
+	}
+	if *calls != 1 {
+		t.Errorf("operation ran %d times, want 1", *calls)
+	}
+}
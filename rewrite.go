@@ -0,0 +1,104 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule maps incoming request paths to another path, like nginx's rewrite
+type RewriteRule struct {
+	// Pattern is a regular expression matched against the request path
+	Pattern string
+	// Target is the replacement path. It can reference capture groups ($1, ${name})
+	// and carry a query string, which is merged with the original query.
+	Target string
+	// Redirect sends an external redirect with this status (e.g. 301 or 302)
+	// instead of rewriting internally. Zero means an internal rewrite.
+	Redirect int
+}
+
+// compiledRewrite is a RewriteRule with its pattern compiled
+type compiledRewrite struct {
+	RewriteRule
+	regexp *regexp.Regexp
+}
+
+// WithRewrites applies rewrite rules to request paths before routing. The first
+// matching rule wins. Internal rewrites serve the target while the client URL stays
+// unchanged; rules with Redirect set send the client to the target instead.
+func WithRewrites(rules []RewriteRule) Option {
+	return func(m *Middleware) {
+		m.rewriteRules = append(m.rewriteRules, rules...)
+	}
+}
+
+// compileRewrites compiles the configured rewrite patterns
+func (m *Middleware) compileRewrites() error {
+	for _, rule := range m.rewriteRules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid rewrite pattern %q: %w", rule.Pattern, err)
+		}
+		if rule.Redirect != 0 && (rule.Redirect < 300 || rule.Redirect > 399) {
+			return fmt.Errorf("invalid redirect status %d for rewrite %q", rule.Redirect, rule.Pattern)
+		}
+		m.rewrites = append(m.rewrites, compiledRewrite{RewriteRule: rule, regexp: re})
+	}
+	return nil
+}
+
+// matchRewrite returns the first rule matching the path along with its expanded target
+func (m *Middleware) matchRewrite(path string) (*compiledRewrite, string) {
+	for i := range m.rewrites {
+		rule := &m.rewrites[i]
+		match := rule.regexp.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		target := rule.regexp.ExpandString(nil, rule.Target, path, match)
+		return rule, string(target)
+	}
+	return nil, ""
+}
+
+// rewriteRequest applies the rewrite rules to r. It returns the request to route
+// and false, or true if it already answered with a redirect.
+func (m *Middleware) rewriteRequest(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	rule, target := m.matchRewrite(r.URL.Path)
+	if rule == nil {
+		return r, false
+	}
+
+	rewritten := rewrittenRequest(r, target)
+
+	if rule.Redirect != 0 {
+		// The target is routed without the base path, but the client needs it back
+		location := m.basePath + rewritten.URL.RequestURI()
+		m.logger.Printf("Redirecting %s to %s (%d)", r.URL.Path, location, rule.Redirect)
+		http.Redirect(w, r, location, rule.Redirect)
+		return r, true
+	}
+
+	m.logger.Printf("Rewrote %s to %s", r.URL.Path, rewritten.URL.RequestURI())
+	return rewritten, false
+}
+
+// rewrittenRequest returns a copy of r pointing at target, merging query strings
+func rewrittenRequest(r *http.Request, target string) *http.Request {
+	rewritten := r.Clone(r.Context())
+
+	path, query, _ := strings.Cut(target, "?")
+	rewritten.URL.Path = path
+	rewritten.URL.RawPath = ""
+
+	if query != "" {
+		if r.URL.RawQuery != "" {
+			query += "&" + r.URL.RawQuery
+		}
+		rewritten.URL.RawQuery = query
+	}
+
+	return rewritten
+}
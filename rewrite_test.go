@@ -0,0 +1,115 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newRewriteMiddleware returns a bare Middleware with compiled rewrite rules
+func newRewriteMiddleware(t *testing.T, rules []RewriteRule, opts ...Option) *Middleware {
+	t.Helper()
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	WithRewrites(rules)(m)
+	for _, opt := range opts {
+		opt(m)
+	}
+	if err := m.compileRewrites(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestInternalRewrite(t *testing.T) {
+	m := newRewriteMiddleware(t, []RewriteRule{
+		{Pattern: `^/old-page$`, Target: "/new-page.php"},
+		{Pattern: `^/blog/(\d+)/(?P<slug>[a-z-]+)$`, Target: "/post.php?id=$1&slug=${slug}"},
+	})
+
+	tests := []struct {
+		target    string
+		wantPath  string
+		wantQuery string
+	}{
+		{"/old-page", "/new-page.php", ""},
+		{"/old-page?ref=mail", "/new-page.php", "ref=mail"},
+		{"/blog/42/hello-world", "/post.php", "id=42&slug=hello-world"},
+		{"/blog/42/hello-world?utm=x", "/post.php", "id=42&slug=hello-world&utm=x"},
+		{"/untouched", "/untouched", ""},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		rewritten, redirected := m.rewriteRequest(recorder, r)
+
+		if redirected {
+			t.Errorf("%s was redirected, want an internal rewrite", tt.target)
+			continue
+		}
+		if rewritten.URL.Path != tt.wantPath || rewritten.URL.RawQuery != tt.wantQuery {
+			t.Errorf("%s rewritten to %s?%s, want %s?%s", tt.target, rewritten.URL.Path, rewritten.URL.RawQuery, tt.wantPath, tt.wantQuery)
+		}
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Location") != "" {
+			t.Errorf("%s: an internal rewrite answered the client (status %d)", tt.target, recorder.Code)
+		}
+		if tt.target != "/untouched" && r.URL.RequestURI() != tt.target {
+			t.Errorf("the caller's request was modified: %s", r.URL.RequestURI())
+		}
+	}
+}
+
+func TestRedirectRewrite(t *testing.T) {
+	m := newRewriteMiddleware(t, []RewriteRule{
+		{Pattern: `^/old/(.*)$`, Target: "/new/$1", Redirect: http.StatusMovedPermanently},
+		{Pattern: `^/temp$`, Target: "/elsewhere", Redirect: http.StatusFound},
+	})
+
+	tests := []struct {
+		target   string
+		status   int
+		location string
+	}{
+		{"/old/page", http.StatusMovedPermanently, "/new/page"},
+		{"/old/page?q=1", http.StatusMovedPermanently, "/new/page?q=1"},
+		{"/temp", http.StatusFound, "/elsewhere"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		if _, redirected := m.rewriteRequest(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil)); !redirected {
+			t.Errorf("%s wasn't redirected", tt.target)
+			continue
+		}
+		if recorder.Code != tt.status || recorder.Header().Get("Location") != tt.location {
+			t.Errorf("%s = %d to %q, want %d to %q", tt.target, recorder.Code, recorder.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
+
+func TestRedirectRewriteKeepsBasePath(t *testing.T) {
+	m := newRewriteMiddleware(t, []RewriteRule{
+		{Pattern: `^/old$`, Target: "/new", Redirect: http.StatusMovedPermanently},
+	}, WithBasePath("/php"))
+
+	// The base path has already been stripped when rewrite rules run
+	r := m.stripBasePath(httptest.NewRequest(http.MethodGet, "/php/old", nil))
+	recorder := httptest.NewRecorder()
+	m.rewriteRequest(recorder, r)
+
+	if location := recorder.Header().Get("Location"); location != "/php/new" {
+		t.Errorf("Location = %q, want /php/new", location)
+	}
+}
+
+func TestCompileRewritesRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []RewriteRule{
+		{Pattern: `^/(unclosed$`, Target: "/x"},
+		{Pattern: `^/x$`, Target: "/y", Redirect: http.StatusOK},
+	} {
+		m := &Middleware{rewriteRules: []RewriteRule{rule}}
+		if err := m.compileRewrites(); err == nil {
+			t.Errorf("rule %+v compiled without error", rule)
+		}
+	}
+}
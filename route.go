@@ -0,0 +1,136 @@
+package frango
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RouteInfo describes the route that matched a request
+type RouteInfo struct {
+	// Method is the HTTP method the route is restricted to (empty for any method)
+	Method string
+	// Pattern is the URL pattern the route was registered or resolved under
+	Pattern string
+	// ScriptPath is the absolute path of the PHP script serving the route
+	ScriptPath string
+}
+
+// routeContextKey is the context key for the matched RouteInfo
+type routeContextKey struct{}
+
+// withRoute returns a shallow copy of r carrying the matched route in its context
+func (m *Middleware) withRoute(r *http.Request, urlPath string, scriptPath string) *http.Request {
+	route := RouteInfo{
+		Pattern:    urlPath,
+		ScriptPath: scriptPath,
+	}
+
+	// Method-specific routes are stored under "METHOD:/path"
+	if _, found := m.routes[r.Method+":"+urlPath]; found {
+		route.Method = r.Method
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), routeContextKey{}, route))
+}
+
+// RouteFromContext returns the route frango matched for the request, if any.
+// It's available to render functions and anything else handed the request frango serves.
+func RouteFromContext(r *http.Request) (RouteInfo, bool) {
+	route, ok := r.Context().Value(routeContextKey{}).(RouteInfo)
+	return route, ok
+}
+
+// ListRoutes returns every registered route, sorted by pattern and method, e.g. for
+// logging the route table at startup or exposing it on a debug endpoint
+func (m *Middleware) ListRoutes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(m.routes))
+	for key, scriptPath := range m.routes {
+		route := RouteInfo{Pattern: key, ScriptPath: scriptPath}
+
+		// Method-specific routes are stored under "METHOD:/path"
+		if method, pattern, found := strings.Cut(key, ":"); found && !strings.HasPrefix(key, "/") {
+			route.Method, route.Pattern = method, pattern
+		}
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// RegisterRoutes mounts routes (e.g. from ListRoutes or LoadRoutesFromPHP) on a Go
+// ServeMux, each running its script. Repeated patterns are registered once; patterns
+// the ServeMux rejects as conflicting are skipped and reported in the returned error.
+func (m *Middleware) RegisterRoutes(mux *http.ServeMux, routes []RouteInfo) error {
+	registered := make(map[string]bool)
+	var conflicts []string
+
+	for _, route := range routes {
+		pattern := route.Pattern
+		if route.Method != "" {
+			pattern = route.Method + " " + pattern
+		}
+		if registered[pattern] {
+			continue
+		}
+
+		scriptPath := route.ScriptPath
+		if err := handleSafely(mux, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serveScript(scriptPath, w, r)
+		})); err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %v", pattern, err))
+			continue
+		}
+		registered[pattern] = true
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicting route patterns:\n%s", strings.Join(conflicts, "\n"))
+	}
+	return nil
+}
+
+// handleSafely registers a handler, turning ServeMux's panic on an invalid or
+// conflicting pattern into an error
+func handleSafely(mux *http.ServeMux, pattern string, handler http.Handler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+	mux.Handle(pattern, handler)
+	return nil
+}
+
+// patternPathValues returns the wildcard values Go's ServeMux matched for the request.
+// ServeMux (Go 1.22+) records the matched pattern on the request, so each {name}
+// segment can be read back with PathValue.
+func patternPathValues(r *http.Request) map[string]string {
+	values := make(map[string]string)
+	if r.Pattern == "" {
+		return values
+	}
+
+	for _, segment := range strings.Split(r.Pattern, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}"), "...")
+		if name == "" || name == "$" {
+			continue
+		}
+
+		values[name] = r.PathValue(name)
+	}
+
+	return values
+}
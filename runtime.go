@@ -0,0 +1,96 @@
+package frango
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// phpRuntime reference-counts the process-wide FrankenPHP runtime so several
+// Middleware instances can share it
+var phpRuntime struct {
+	mutex sync.Mutex
+	// users is the number of initialized instances
+	users int
+	// ini holds the directives PHP was started with, which every instance shares
+	ini map[string]string
+	// retained lists temp directories of shut down instances that must outlive them,
+	// because PHP still prepends the helper script the first instance wrote
+	retained []string
+}
+
+// acquirePHP starts FrankenPHP for the first instance and registers later ones. Worker
+// pools, the thread count and ini directives are part of FrankenPHP's startup, so only
+// the first instance can set them; later ones must ask for the same directives.
+func acquirePHP(workers map[string]int, numThreads int, ini map[string]string) (first bool, err error) {
+	phpRuntime.mutex.Lock()
+	defer phpRuntime.mutex.Unlock()
+
+	if phpRuntime.users == 0 {
+		if err := startPHP(workers, numThreads); err != nil {
+			return false, err
+		}
+		phpRuntime.ini = ini
+	} else if differing := differingDirectives(phpRuntime.ini, ini); len(differing) > 0 {
+		return false, fmt.Errorf("FrankenPHP is already running for another frango instance with different PHP ini settings (%s), ini directives can only be configured on the first one", strings.Join(differing, ", "))
+	} else if len(workers) > 0 {
+		return false, fmt.Errorf("FrankenPHP is already running for another frango instance, worker scripts can only be configured on the first one")
+	} else if numThreads > 0 {
+		return false, fmt.Errorf("FrankenPHP is already running for another frango instance, the thread count can only be configured on the first one")
+	}
+
+	phpRuntime.users++
+	return phpRuntime.users == 1, nil
+}
+
+// releasePHP unregisters an instance and stops FrankenPHP when it was the last one.
+// The instance's temp directory is removed then, or kept until the runtime stops.
+func releasePHP(tempDir string) {
+	phpRuntime.mutex.Lock()
+	defer phpRuntime.mutex.Unlock()
+
+	phpRuntime.users--
+	if phpRuntime.users > 0 {
+		phpRuntime.retained = append(phpRuntime.retained, tempDir)
+		return
+	}
+
+	stopPHP()
+	phpRuntime.ini = nil
+	for _, dir := range append(phpRuntime.retained, tempDir) {
+		os.RemoveAll(dir)
+	}
+	phpRuntime.retained = nil
+}
+
+// retainedByPHP reports whether a temp directory is kept alive for the shared runtime
+func retainedByPHP(tempDir string) bool {
+	phpRuntime.mutex.Lock()
+	defer phpRuntime.mutex.Unlock()
+
+	for _, dir := range phpRuntime.retained {
+		if dir == tempDir {
+			return true
+		}
+	}
+	return false
+}
+
+// differingDirectives returns the sorted names of the directives set differently in a and b
+func differingDirectives(a map[string]string, b map[string]string) []string {
+	var differing []string
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			differing = append(differing, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			differing = append(differing, key)
+		}
+	}
+	sort.Strings(differing)
+	return differing
+}
@@ -0,0 +1,145 @@
+package frango
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SessionStore persists PHP session data in Go so $_SESSION survives across
+// requests regardless of which environment serves them
+type SessionStore interface {
+	// Get returns the serialized session data for an ID
+	Get(id string) ([]byte, bool)
+	// Set stores the serialized session data for an ID
+	Set(id string, data []byte)
+	// Destroy removes a session
+	Destroy(id string)
+}
+
+// MemorySessionStore is an in-memory SessionStore
+type MemorySessionStore struct {
+	sessions map[string][]byte
+	mutex    sync.RWMutex
+}
+
+// NewMemorySessionStore creates an empty in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string][]byte),
+	}
+}
+
+// Get returns the serialized session data for an ID
+func (s *MemorySessionStore) Get(id string) ([]byte, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	data, found := s.sessions[id]
+	return data, found
+}
+
+// Set stores the serialized session data for an ID
+func (s *MemorySessionStore) Set(id string, data []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[id] = data
+}
+
+// Destroy removes a session
+func (s *MemorySessionStore) Destroy(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.sessions, id)
+}
+
+// WithSessionStore makes PHP sessions use a Go-side store. The prepended helper
+// script installs a session save handler that reads the session from the request
+// environment and journals writes to a file frango applies once the script ends.
+func WithSessionStore(store SessionStore) Option {
+	return func(m *Middleware) {
+		m.sessionStore = store
+	}
+}
+
+// sessionJournalEntry is one write or destroy recorded by the PHP save handler
+type sessionJournalEntry struct {
+	Op   string `json:"op"`
+	ID   string `json:"id"`
+	Data string `json:"data"`
+}
+
+// sessionCookieName returns the cookie PHP uses for the session ID
+func (m *Middleware) sessionCookieName() string {
+	if name := strings.Trim(m.phpIni["session.name"], `"'`); name != "" {
+		return name
+	}
+	return "PHPSESSID"
+}
+
+// prepareSession loads the request's session into the PHP environment and returns
+// the journal file the save handler records changes to
+func (m *Middleware) prepareSession(r *http.Request, phpEnv map[string]string) (string, error) {
+	sessionDir := filepath.Join(m.tempDir, "sessions")
+	if err := os.MkdirAll(sessionDir, 0755); err != nil {
+		return "", fmt.Errorf("error creating session directory: %w", err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("error generating session journal name: %w", err)
+	}
+	journalPath := filepath.Join(sessionDir, hex.EncodeToString(suffix)+".jsonl")
+	phpEnv["frango_SESSION_JOURNAL"] = journalPath
+
+	if cookie, err := r.Cookie(m.sessionCookieName()); err == nil && cookie.Value != "" {
+		phpEnv["frango_SESSION_ID"] = cookie.Value
+		if data, found := m.sessionStore.Get(cookie.Value); found {
+			phpEnv["frango_SESSION_DATA"] = base64.StdEncoding.EncodeToString(data)
+		}
+	}
+
+	return journalPath, nil
+}
+
+// commitSession applies the session changes journaled by PHP to the store
+func (m *Middleware) commitSession(journalPath string) error {
+	file, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		// The script didn't touch the session
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening session journal: %w", err)
+	}
+	defer os.Remove(journalPath)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var entry sessionJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("error parsing session journal: %w", err)
+		}
+
+		switch entry.Op {
+		case "write":
+			data, err := base64.StdEncoding.DecodeString(entry.Data)
+			if err != nil {
+				return fmt.Errorf("error decoding session data: %w", err)
+			}
+			m.sessionStore.Set(entry.ID, data)
+		case "destroy":
+			m.sessionStore.Destroy(entry.ID)
+		}
+	}
+	return scanner.Err()
+}
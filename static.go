@@ -0,0 +1,59 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WithStaticMaxAge sets the Cache-Control max-age for static files served from the
+// source directory in production mode. Development mode always sends no-cache.
+func WithStaticMaxAge(maxAge time.Duration) Option {
+	return func(m *Middleware) {
+		m.staticMaxAge = maxAge
+	}
+}
+
+// StaticHandler returns a handler that serves non-PHP files (CSS, JS, images...)
+// straight from the source directory with content-type and caching headers, and
+// hands everything else to PHP through ServeHTTP.
+func (m *Middleware) StaticHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if staticPath, ok := m.staticFilePath(r.URL.Path); ok {
+			m.serveStatic(w, r, staticPath)
+			return
+		}
+		m.ServeHTTP(w, r)
+	})
+}
+
+// staticFilePath returns the file in the source directory a URL path maps to, if
+// it exists and isn't a PHP script
+func (m *Middleware) staticFilePath(urlPath string) (string, bool) {
+	cleanPath := path.Clean("/" + urlPath)
+	if m.isPHPFile(cleanPath) || m.isBlockedPath(cleanPath) {
+		return "", false
+	}
+
+	filePath := filepath.Join(m.sourceDir, filepath.FromSlash(strings.TrimPrefix(cleanPath, "/")))
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	return filePath, true
+}
+
+// serveStatic serves a file with caching headers; content type, Last-Modified and
+// conditional requests are handled by http.ServeFile
+func (m *Middleware) serveStatic(w http.ResponseWriter, r *http.Request, filePath string) {
+	if m.developmentMode {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(m.staticMaxAge.Seconds())))
+	}
+	http.ServeFile(w, r, filePath)
+}
@@ -0,0 +1,51 @@
+package frango
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithResponseStreaming guarantees PHP output is sent to the client as soon as the
+// script calls flush(), e.g. for server-sent events or progress streams. Features
+// that buffer the whole response are bypassed: the response cache, WithStrictErrors,
+// WithETag and WithResponseTransformer, while WithRequestTimeout keeps its deadline but
+// can't answer 504 once output has started. Proxies are asked not to buffer via
+// X-Accel-Buffering: no.
+func WithResponseStreaming(enabled bool) Option {
+	return func(m *Middleware) {
+		m.responseStreaming = enabled
+	}
+}
+
+// warnStreamingConflicts logs the configured features that streaming disables
+func (m *Middleware) warnStreamingConflicts() {
+	if !m.responseStreaming {
+		return
+	}
+
+	var bypassed []string
+	if m.responseCache != nil {
+		bypassed = append(bypassed, "WithResponseCache")
+	}
+	if m.etags {
+		bypassed = append(bypassed, "WithETag")
+	}
+	if m.responseTransformer != nil {
+		bypassed = append(bypassed, "WithResponseTransformer")
+	}
+	if m.requestTimeout > 0 {
+		bypassed = append(bypassed, "WithRequestTimeout (deadline only, no 504)")
+	}
+
+	if len(bypassed) > 0 {
+		m.logger.Printf("WARNING: WithResponseStreaming bypasses buffering features: %s", strings.Join(bypassed, ", "))
+	}
+}
+
+// prepareStreaming sets the headers that keep intermediaries from buffering the response
+func (m *Middleware) prepareStreaming(w http.ResponseWriter) {
+	if !m.responseStreaming {
+		return
+	}
+	w.Header().Set("X-Accel-Buffering", "no")
+}
@@ -0,0 +1,64 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventsScript emits a server-sent event every second
+const eventsScript = `<?php
+header('Content-Type: text/event-stream');
+for ($i = 1; $i <= 3; $i++) {
+    echo "data: tick $i\n\n";
+    flush();
+    sleep(1);
+}
+`
+
+func TestResponseStreamingDeliversFlushedChunks(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{"events.php": eventsScript},
+		quietLogger(),
+		WithResponseStreaming(true),
+		WithETag(true),
+		WithRequestTimeout(10*time.Second),
+	)
+	defer cleanup()
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	started := time.Now()
+	resp, err := http.Get(server.URL + "/events.php")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("X-Accel-Buffering") != "no" {
+		t.Error("X-Accel-Buffering: no missing from the streamed response")
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for i := 1; i <= 3; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event %d: %v", i, err)
+		}
+		if want := "data: tick "; !strings.HasPrefix(line, want) {
+			t.Fatalf("event %d = %q, want a %q line", i, line, want)
+		}
+		reader.ReadString('\n')
+
+		// The script sleeps a second after each event, so the first one can only
+		// arrive this early if it was flushed rather than buffered until the end
+		if i == 1 && time.Since(started) > 1500*time.Millisecond {
+			t.Errorf("first event arrived after %s, output was buffered", time.Since(started))
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package frango
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flushRecorder records how much of the body had reached it at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+// Flush records the body length seen by the client so far
+func (f *flushRecorder) Flush() {
+	f.flushedAt = append(f.flushedAt, f.Body.Len())
+	f.ResponseRecorder.Flush()
+}
+
+func TestWriterChainFlushesIncrementally(t *testing.T) {
+	m := &Middleware{compression: true, compressionLevel: -1}
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	client := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	// The same wrappers servePHPFileWithPathParams puts between PHP and the client
+	var w http.ResponseWriter = &statusRecorder{ResponseWriter: client}
+	w = m.newCompressWriter(w, r)
+	w = newAnnotatingWriter(w, r, "events.php")
+	w = &defaultHeadersWriter{ResponseWriter: w, noSniff: true}
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	// The body is gzipped, so check that each flush delivers more of it
+	previous := 0
+	for i, chunk := range []string{"data: one\n\n", "data: two\n\n", "data: three\n\n"} {
+		w.Write([]byte(chunk))
+		w.(http.Flusher).Flush()
+
+		if len(client.flushedAt) != i+1 {
+			t.Fatalf("after chunk %d the client saw %d flushes, want %d", i+1, len(client.flushedAt), i+1)
+		}
+		if client.flushedAt[i] <= previous {
+			t.Fatalf("chunk %d didn't reach the client when flushed (%d bytes before, %d after)", i+1, previous, client.flushedAt[i])
+		}
+		previous = client.flushedAt[i]
+	}
+	if client.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("response wasn't compressed, the compressor wasn't exercised")
+	}
+}
+
+func TestStreamingSkipsBufferingFeatures(t *testing.T) {
+	var logs bytes.Buffer
+	_, cleanup := NewTestInstance(nil,
+		WithLogger(log.New(&logs, "", 0)),
+		WithResponseStreaming(true),
+		WithETag(true),
+		WithRequestTimeout(time.Second),
+	)
+	defer cleanup()
+
+	warning := logs.String()
+	for _, feature := range []string{"WithETag", "WithRequestTimeout"} {
+		if !strings.Contains(warning, feature) {
+			t.Errorf("no streaming warning for %s in:\n%s", feature, warning)
+		}
+	}
+}
+
+func TestStreamingRefusesStrictErrors(t *testing.T) {
+	m, err := New(quietLogger(), WithResponseStreaming(true), WithStrictErrors(true))
+	if err == nil {
+		m.Shutdown()
+		t.Fatal("New accepted WithStrictErrors with WithResponseStreaming")
+	}
+}
@@ -0,0 +1,74 @@
+package frango
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// scriptErrorMarker is printed by PHP before every error it displays in strict mode
+// (error_prepend_string), so errors are told apart from page text that looks like one.
+// It's random per process, but the same for every instance sharing PHP.
+var scriptErrorMarker = "<!-- frango:error:" + newRequestID()[:16] + " -->"
+
+// phpErrorPattern matches the errors PHP prints with display_errors after the marker,
+// in both the HTML ("<br />\n<b>Warning</b>:  ... in <b>file</b> on line <b>3</b>") and
+// plain text forms
+var phpErrorPattern = regexp.MustCompile(regexp.QuoteMeta(scriptErrorMarker) + `(?:<br />)?\s*(?:<b>)?(Fatal error|Parse error|Recoverable fatal error|Warning|Notice|Deprecated)(?:</b>)?:\s+(.+?) in (?:<b>)?(\S+?)(?:</b>)? on line (?:<b>)?(\d+)`)
+
+// ScriptError is a PHP error or warning found in a script's output in strict mode
+type ScriptError struct {
+	// Level is the PHP error level, e.g. "Fatal error" or "Warning"
+	Level string
+	// Message is the error message
+	Message string
+	// File and Line locate the error
+	File string
+	Line string
+}
+
+// Error implements the error interface
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("PHP %s: %s in %s on line %s", e.Level, e.Message, e.File, e.Line)
+}
+
+// findScriptError returns the first error PHP displayed in output, if any
+func findScriptError(output []byte) *ScriptError {
+	match := phpErrorPattern.FindSubmatch(output)
+	if match == nil {
+		return nil
+	}
+	return &ScriptError{
+		Level:   string(match[1]),
+		Message: string(match[2]),
+		File:    string(match[3]),
+		Line:    string(match[4]),
+	}
+}
+
+// WithStrictErrors buffers PHP output and, when it contains a PHP error or warning,
+// responds with a 500 (or the WithErrorHandler page) instead of sending the broken
+// page. The error is logged and reported as a PHPError with the PHPErrorScript category.
+// It turns on display_errors so PHP prints the errors it finds, marked so that page
+// text merely resembling an error isn't mistaken for one, and responses are no longer
+// streamed. Both ini directives apply to the whole PHP process, and New refuses to
+// combine it with WithResponseStreaming, which would send the errors to clients.
+func WithStrictErrors(enabled bool) Option {
+	return func(m *Middleware) {
+		m.strictErrors = enabled
+	}
+}
+
+// applyStrictErrorIni makes PHP display errors, marked, for strict mode to find
+func (m *Middleware) applyStrictErrorIni() error {
+	if !m.strictErrors {
+		return nil
+	}
+	if m.responseStreaming {
+		return fmt.Errorf("WithStrictErrors can't be combined with WithResponseStreaming: the PHP errors it displays would be streamed to clients")
+	}
+	if _, configured := m.phpIni["display_errors"]; !configured {
+		m.phpIni["display_errors"] = "1"
+	}
+	m.phpIni["error_prepend_string"] = `"` + scriptErrorMarker + `"`
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStrictErrorsAnswer500(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"broken.php":  `<?php echo "<p>" . $undefined . "</p>";`,
+		"warning.php": `<?php echo "<p>Warning: the value in field.php on line 3 is invalid</p>";`,
+	}, quietLogger(), WithStrictErrors(true))
+	defer cleanup()
+
+	if recorder := serve(m, http.MethodGet, "/broken.php"); recorder.Code != http.StatusInternalServerError {
+		t.Errorf("a script printing a PHP warning got %d, want 500:\n%s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(m, http.MethodGet, "/warning.php"); recorder.Code != http.StatusOK {
+		t.Errorf("a page merely mentioning a warning got %d, want 200", recorder.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package frango
+
+import "testing"
+
+func TestFindScriptError(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		level  string
+	}{
+		{"html", "<p>start</p>" + scriptErrorMarker + "<br />\n<b>Warning</b>:  Undefined variable $name in <b>/app/page.php</b> on line <b>3</b><br />\n", "Warning"},
+		{"text", scriptErrorMarker + "\nFatal error: Uncaught Error: boom in /app/page.php on line 7\n", "Fatal error"},
+		{"page text", "<p>Warning: the value in field.php on line 3 is invalid</p>", ""},
+		{"docs page", "<pre>Notice: Undefined index: id in /var/www/x.php on line 12</pre>", ""},
+		{"clean", "<p>hello</p>", ""},
+	}
+	for _, tt := range tests {
+		scriptErr := findScriptError([]byte(tt.output))
+		if tt.level == "" {
+			if scriptErr != nil {
+				t.Errorf("%s: false positive %v", tt.name, scriptErr)
+			}
+			continue
+		}
+		if scriptErr == nil || scriptErr.Level != tt.level {
+			t.Errorf("%s: found %v, want a %s", tt.name, scriptErr, tt.level)
+		}
+	}
+}
+
+func TestStrictErrorsIni(t *testing.T) {
+	m, cleanup := NewTestInstance(nil, quietLogger(), WithStrictErrors(true))
+	defer cleanup()
+	if m.phpIni["display_errors"] != "1" || m.phpIni["error_prepend_string"] != `"`+scriptErrorMarker+`"` {
+		t.Errorf("ini = %v, want display_errors and the error marker", m.phpIni)
+	}
+
+	m, cleanup = NewTestInstance(nil, quietLogger(), WithStrictErrors(true), WithPHPIni(map[string]string{"display_errors": "stderr"}))
+	defer cleanup()
+	if m.phpIni["display_errors"] != "stderr" {
+		t.Errorf("display_errors = %q, want the configured stderr", m.phpIni["display_errors"])
+	}
+}
@@ -0,0 +1,47 @@
+package frango
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// NewTestInstance builds a Middleware over a fresh temporary source directory
+// populated from files (slash-separated path relative to the source directory ->
+// content), for tests that would otherwise write scripts to disk by hand. Extra
+// options are applied after the source directory. The returned cleanup shuts the
+// instance down and removes the directory. Like httptest.NewServer, it panics when
+// the instance can't be set up.
+func NewTestInstance(files map[string]string, opts ...Option) (*Middleware, func()) {
+	sourceDir, err := os.MkdirTemp("", "frango-test")
+	if err != nil {
+		panic(fmt.Sprintf("frango: error creating test source directory: %v", err))
+	}
+
+	for name, content := range files {
+		// Cleaning a rooted path keeps ".." segments inside the source directory
+		name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+		filePath := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			os.RemoveAll(sourceDir)
+			panic(fmt.Sprintf("frango: error creating directory for test file %s: %v", name, err))
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			os.RemoveAll(sourceDir)
+			panic(fmt.Sprintf("frango: error writing test file %s: %v", name, err))
+		}
+	}
+
+	m, err := New(append([]Option{WithSourceDir(sourceDir)}, opts...)...)
+	if err != nil {
+		os.RemoveAll(sourceDir)
+		panic(fmt.Sprintf("frango: error creating test instance: %v", err))
+	}
+
+	return m, func() {
+		m.Shutdown()
+		os.RemoveAll(sourceDir)
+	}
+}
@@ -0,0 +1,65 @@
+package frango
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// WithRequestTimeout limits how long frango waits for a PHP route. The request context
+// gets the deadline, which PHP also receives as its max_execution_time, and a route that
+// hasn't finished in time is answered with 504 Gateway Timeout. Output is buffered while
+// a timeout is set, unless WithResponseStreaming is on: then only the deadline applies.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(m *Middleware) {
+		m.requestTimeout = timeout
+	}
+}
+
+// handleWithTimeout runs handlePHPFile under the configured request timeout
+func (m *Middleware) handleWithTimeout(urlPath string, sourcePath string, w http.ResponseWriter, r *http.Request) {
+	if m.requestTimeout <= 0 {
+		m.handlePHPFile(urlPath, sourcePath, w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), m.requestTimeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	// Streamed output goes straight to the client, PHP stops itself at the deadline
+	if m.responseStreaming {
+		m.handlePHPFile(urlPath, sourcePath, w, r)
+		return
+	}
+
+	// PHP writes into its own buffer so a late script can't touch w after we've answered
+	buffered := newBufferedResponse()
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer close(done)
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		m.handlePHPFile(urlPath, sourcePath, buffered, r)
+	}()
+
+	select {
+	case <-done:
+		select {
+		case p := <-panicked:
+			panic(p)
+		default:
+		}
+		buffered.writeTo(w)
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			m.logger.Printf("PHP request for %s timed out after %s", urlPath, m.requestTimeout)
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+		}
+	}
+}
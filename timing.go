@@ -0,0 +1,28 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WithServerTiming adds a Server-Timing header to PHP responses. frango reports the
+// time spent preparing the environment (frango-env) and, just before headers are
+// sent, the time PHP ran (frango-php). Scripts add their own phases with
+// frango_timing('db', $ms), as long as they do so before output starts.
+func WithServerTiming(enabled bool) Option {
+	return func(m *Middleware) {
+		m.serverTiming = enabled
+	}
+}
+
+// addServerTiming records the Go-side preparation phase and enables the PHP-side phases
+func (m *Middleware) addServerTiming(w http.ResponseWriter, phpEnv map[string]string, started time.Time) {
+	if !m.serverTiming {
+		return
+	}
+
+	elapsed := float64(time.Since(started).Microseconds()) / 1000
+	w.Header().Add("Server-Timing", fmt.Sprintf("frango-env;dur=%.2f", elapsed))
+	phpEnv["frango_SERVER_TIMING"] = "1"
+}
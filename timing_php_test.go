@@ -0,0 +1,42 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestServerTimingMergesPHPPhases(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{
+		"page.php": `<?php
+frango_timing('db', 12.345, 'Query "users"');
+frango_timing('cache', 0.5);
+echo "done";
+frango_timing('late', 1);
+`,
+	}, quietLogger(), WithServerTiming(true))
+	defer cleanup()
+
+	recorder := serve(m, http.MethodGet, "/page.php")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /page.php = %d", recorder.Code)
+	}
+
+	want := []*regexp.Regexp{
+		regexp.MustCompile(`^frango-env;dur=\d+\.\d{2}$`),
+		regexp.MustCompile(`^db;dur=12\.35;desc="Query \\"users\\""$`),
+		regexp.MustCompile(`^cache;dur=0\.5$`),
+		regexp.MustCompile(`^frango-php;dur=\d+(\.\d+)?$`),
+	}
+	timing := recorder.Header().Values("Server-Timing")
+	if len(timing) != len(want) {
+		t.Fatalf("Server-Timing = %q, want %d phases (the one after output dropped)", timing, len(want))
+	}
+	for i, pattern := range want {
+		if !pattern.MatchString(timing[i]) {
+			t.Errorf("Server-Timing[%d] = %q, want %s", i, timing[i], pattern)
+		}
+	}
+}
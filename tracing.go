@@ -0,0 +1,55 @@
+package frango
+
+import (
+	"net/http"
+)
+
+// Tracer starts a span for each PHP request, e.g. an adapter around an OpenTelemetry tracer
+type Tracer interface {
+	// StartSpan starts a span for the request running scriptPath and returns the
+	// request carrying the span's context
+	StartSpan(r *http.Request, scriptPath string) (*http.Request, TraceSpan)
+}
+
+// TraceSpan is a span started by a Tracer
+type TraceSpan interface {
+	// TraceContext returns the W3C traceparent and tracestate of the span
+	TraceContext() (traceparent string, tracestate string)
+	// End finishes the span with the response status and the PHP error, if any
+	End(status int, err error)
+}
+
+// WithTracing creates a span per PHP request through tracer. The span's trace context
+// is passed to PHP as $_SERVER['TRACEPARENT'] and $_SERVER['TRACESTATE'] (the OpenTelemetry
+// environment carrier keys) so PHP can continue the trace in downstream calls, and the
+// span ends with the response status once PHP returns.
+func WithTracing(tracer Tracer) Option {
+	return func(m *Middleware) {
+		m.tracer = tracer
+	}
+}
+
+// startTrace starts a span for a PHP request and adds its trace context to env. It
+// returns the request and writer to use, and a function ending the span.
+func (m *Middleware) startTrace(w http.ResponseWriter, r *http.Request, scriptPath string, env map[string]string) (*http.Request, http.ResponseWriter, func(err error)) {
+	if m.tracer == nil {
+		return r, w, func(error) {}
+	}
+
+	r, span := m.tracer.StartSpan(r, scriptPath)
+	if traceparent, tracestate := span.TraceContext(); traceparent != "" {
+		env["TRACEPARENT"] = traceparent
+		if tracestate != "" {
+			env["TRACESTATE"] = tracestate
+		}
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w}
+	return r, recorder, func(err error) {
+		status := recorder.statusCode()
+		if err != nil && recorder.status == 0 {
+			status = http.StatusInternalServerError
+		}
+		span.End(status, err)
+	}
+}
@@ -0,0 +1,29 @@
+package frango
+
+// ResponseTransformer rewrites a PHP response body, e.g. to inject a debug toolbar,
+// rewrite asset URLs or minify HTML. It receives the response's Content-Type.
+type ResponseTransformer func(contentType string, body []byte) []byte
+
+// WithResponseTransformer passes every PHP response body through transform before it's
+// sent. The output is buffered to do so, which defeats streaming and flush(), so only
+// register a transformer when needed.
+func WithResponseTransformer(transform ResponseTransformer) Option {
+	return func(m *Middleware) {
+		m.responseTransformer = transform
+	}
+}
+
+// transformResponse replaces a buffered body with the transformer's output
+func (m *Middleware) transformResponse(buffered *bufferedResponse) {
+	contentType := buffered.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+
+	body := m.responseTransformer(contentType, buffered.body.Bytes())
+	buffered.body.Reset()
+	buffered.body.Write(body)
+
+	// A length set by the script no longer matches the body
+	buffered.Header().Del("Content-Length")
+}
@@ -0,0 +1,23 @@
+package frango
+
+import "net/http"
+
+// WithMaxURLLength rejects requests whose URL (path and query string) is longer than
+// maxLength bytes with 414 URI Too Long, before any routing or PHP work. 0 disables the limit.
+func WithMaxURLLength(maxLength int) Option {
+	return func(m *Middleware) {
+		m.maxURLLength = maxLength
+	}
+}
+
+// urlTooLong reports whether the request URL exceeds the configured limit
+func (m *Middleware) urlTooLong(r *http.Request) bool {
+	if m.maxURLLength <= 0 {
+		return false
+	}
+	if length := len(r.URL.RequestURI()); length > m.maxURLLength {
+		m.logger.Printf("Rejected request URL of %d bytes (limit %d)", length, m.maxURLLength)
+		return true
+	}
+	return false
+}
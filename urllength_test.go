@@ -0,0 +1,36 @@
+package frango
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestURLTooLong(t *testing.T) {
+	m := &Middleware{logger: log.New(io.Discard, "", 0)}
+	WithMaxURLLength(32)(m)
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"/", false},
+		{"/" + strings.Repeat("a", 31), false},
+		{"/" + strings.Repeat("a", 32), true},
+		{"/search?q=" + strings.Repeat("x", 22), false},
+		{"/search?q=" + strings.Repeat("x", 23), true},
+	}
+	for _, tt := range tests {
+		if got := m.urlTooLong(httptest.NewRequest(http.MethodGet, tt.target, nil)); got != tt.want {
+			t.Errorf("urlTooLong(%d bytes) = %v, want %v", len(tt.target), got, tt.want)
+		}
+	}
+
+	m.maxURLLength = 0
+	if m.urlTooLong(httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("a", 100000), nil)) {
+		t.Error("a limit of 0 rejected a URL")
+	}
+}
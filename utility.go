@@ -0,0 +1,251 @@
+package frango
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// utilityFileName is the name of the generated helper script in the temp directory
+const utilityFileName = "_frango_utility.php"
+
+// utilityScript is prepended to every PHP script through auto_prepend_file and
+// exposes the request data frango passes through $_SERVER as helper functions
+const utilityScript = `<?php
+// Generated by frango - helpers for reading request data passed from Go
+
+if (!function_exists('frango_render_keys')) {
+    /**
+     * Returns the keys of the render data supplied by the Go render function.
+     */
+    function frango_render_keys(): array
+    {
+        $keys = [];
+        foreach ($_SERVER as $name => $value) {
+            if (strncmp($name, 'frango_VAR_', 11) === 0) {
+                $keys[] = substr($name, 11);
+            }
+        }
+        return $keys;
+    }
+}
+
+if (!function_exists('frango_var')) {
+    /**
+     * Returns a render variable decoded from JSON, or $default when it's missing or invalid.
+     */
+    function frango_var(string $key, mixed $default = null): mixed
+    {
+        if (!isset($_SERVER['frango_VAR_' . $key])) {
+            return $default;
+        }
+        $value = json_decode($_SERVER['frango_VAR_' . $key], true);
+        if ($value === null && json_last_error() !== JSON_ERROR_NONE) {
+            return $default;
+        }
+        return $value;
+    }
+}
+
+if (!function_exists('frango_timing')) {
+    /**
+     * Adds a phase to the Server-Timing header (WithServerTiming). Phases must be
+     * recorded before output starts, once headers are sent they are dropped.
+     */
+    function frango_timing(string $name, float $ms, ?string $description = null): void
+    {
+        if (empty($_SERVER['frango_SERVER_TIMING']) || headers_sent()) {
+            return;
+        }
+        $entry = $name . ';dur=' . round($ms, 2);
+        if ($description !== null) {
+            $entry .= ';desc="' . addcslashes($description, '"\\') . '"';
+        }
+        header('Server-Timing: ' . $entry, false);
+    }
+}
+
+if (!function_exists('frango_include')) {
+    /**
+     * Includes a partial like include, with $vars as its local variables, and returns
+     * its result. With WithSourceAnnotations its HTML output is wrapped in comments
+     * naming it. Relative paths are resolved against the calling script's directory.
+     */
+    function frango_include(string $file, array $vars = []): mixed
+    {
+        if (!preg_match('#^(/|[A-Za-z]:[\\\\/])#', $file)) {
+            $caller = debug_backtrace(DEBUG_BACKTRACE_IGNORE_ARGS, 1)[0]['file'] ?? '';
+            $file = dirname($caller) . '/' . $file;
+        }
+
+        $root = realpath($_SERVER['frango_ANNOTATE'] ?? '');
+        $annotate = $root !== false && ($_SERVER['frango_ANNOTATE'] ?? '') !== '';
+        foreach (headers_list() as $header) {
+            if (stripos($header, 'content-type:') === 0 && stripos($header, 'text/html') === false) {
+                $annotate = false;
+            }
+        }
+
+        $name = $file;
+        if ($annotate) {
+            $real = realpath($file);
+            if ($real !== false && strncmp($real, $root . DIRECTORY_SEPARATOR, strlen($root) + 1) === 0) {
+                $name = str_replace(DIRECTORY_SEPARATOR, '/', substr($real, strlen($root) + 1));
+            }
+            echo "<!-- frango: begin $name -->\n";
+        }
+        $result = (static function () {
+            extract(func_get_arg(1));
+            return include func_get_arg(0);
+        })($file, $vars);
+        if ($annotate) {
+            echo "\n<!-- frango: end $name -->\n";
+        }
+        return $result;
+    }
+}
+
+// Report PHP's own run time just before headers are sent
+if (!empty($_SERVER['frango_SERVER_TIMING'])) {
+    header_register_callback(function () {
+        $elapsed = (microtime(true) - $_SERVER['REQUEST_TIME_FLOAT']) * 1000;
+        header('Server-Timing: frango-php;dur=' . round($elapsed, 2), false);
+    });
+}
+
+// Stop before the Go request deadline, e.g. from http.TimeoutHandler or an upstream context
+if (isset($_SERVER['frango_MAX_EXECUTION_TIME'])) {
+    set_time_limit((int) $_SERVER['frango_MAX_EXECUTION_TIME']);
+}
+
+// PHP only fills $_POST and $_FILES for POST; parse PUT, PATCH and DELETE form bodies the same way
+if (in_array($_SERVER['REQUEST_METHOD'] ?? '', ['PUT', 'PATCH', 'DELETE'], true) && empty($_POST)) {
+    $contentType = strtolower($_SERVER['CONTENT_TYPE'] ?? '');
+    if (strncmp($contentType, 'application/x-www-form-urlencoded', 33) === 0) {
+        parse_str(file_get_contents('php://input'), $_POST);
+    } elseif (strncmp($contentType, 'multipart/form-data', 19) === 0 && function_exists('request_parse_body')) {
+        [$_POST, $_FILES] = request_parse_body();
+    }
+    $_REQUEST = array_merge($_GET, $_POST);
+    unset($contentType);
+}
+
+// Session save handler backed by the Go session store (WithSessionStore)
+if (isset($_SERVER['frango_SESSION_JOURNAL']) && !class_exists('FrangoSessionHandler', false)) {
+    class FrangoSessionHandler implements SessionHandlerInterface
+    {
+        public function open(string $path, string $name): bool
+        {
+            return true;
+        }
+
+        public function close(): bool
+        {
+            return true;
+        }
+
+        public function read(string $id): string|false
+        {
+            if ($id === ($_SERVER['frango_SESSION_ID'] ?? null)) {
+                return base64_decode($_SERVER['frango_SESSION_DATA'] ?? '');
+            }
+            return '';
+        }
+
+        public function write(string $id, string $data): bool
+        {
+            return $this->journal(['op' => 'write', 'id' => $id, 'data' => base64_encode($data)]);
+        }
+
+        public function destroy(string $id): bool
+        {
+            return $this->journal(['op' => 'destroy', 'id' => $id, 'data' => '']);
+        }
+
+        public function gc(int $max_lifetime): int|false
+        {
+            return 0;
+        }
+
+        private function journal(array $entry): bool
+        {
+            $line = json_encode($entry) . "\n";
+            return file_put_contents($_SERVER['frango_SESSION_JOURNAL'], $line, FILE_APPEND | LOCK_EX) !== false;
+        }
+    }
+
+    session_set_save_handler(new FrangoSessionHandler(), true);
+}
+
+// Request data superglobals, unless turned off with WithPathSuperglobals(false)
+if (empty($_SERVER['frango_NO_SUPERGLOBALS'])) {
+    // Path parameters, e.g. $_PATH['id'] for a route mounted at /users/{id}
+    $_PATH = [];
+    foreach (json_decode($_SERVER['PATH_PARAMS'] ?? '{}', true) ?: [] as $name => $value) {
+        if ($name !== 'RENDER' && strncmp($name, 'frango_VAR_', 11) !== 0) {
+            $_PATH[$name] = $value;
+        }
+    }
+    unset($name, $value);
+
+    // Query parameters keeping every value of repeated keys, e.g. ?tag=a&tag=b gives
+    // $_QUERY['tag'] === ['a', 'b'] where $_GET only keeps 'b'
+    $_QUERY = [];
+    foreach (explode('&', $_SERVER['QUERY_STRING'] ?? '') as $pair) {
+        if ($pair === '') {
+            continue;
+        }
+        [$name, $value] = array_map('urldecode', array_pad(explode('=', $pair, 2), 2, ''));
+        $_QUERY[$name] = array_key_exists($name, $_QUERY) ? [...(array) $_QUERY[$name], $value] : $value;
+    }
+    unset($pair, $name, $value);
+
+    // Negotiated content type (WithContentTypes), e.g. $_NEGOTIATED['format'] === 'json'
+    $_NEGOTIATED = [];
+    if (isset($_SERVER['FRANGO_ACCEPT_TYPE'])) {
+        $_NEGOTIATED['type'] = $_SERVER['FRANGO_ACCEPT_TYPE'];
+        $_NEGOTIATED['format'] = preg_replace('/^.*[\/+]/', '', $_SERVER['FRANGO_ACCEPT_TYPE']);
+    }
+
+    // Render data decoded into arrays, e.g. $_RENDER['user']['name']
+    $_RENDER = [];
+    foreach (frango_render_keys() as $key) {
+        $_RENDER[$key] = json_decode($_SERVER['frango_VAR_' . $key], true);
+    }
+    unset($key);
+}
+`
+
+// WithPathSuperglobals controls whether the helper script defines the $_PATH, $_QUERY,
+// $_RENDER and $_NEGOTIATED globals (enabled by default). Turning it off leaves the
+// script's global scope untouched; the data stays available in $_SERVER (PATH_PARAM_*,
+// QUERY_PARAM_ARRAY_*, frango_VAR_*, FRANGO_ACCEPT_TYPE) and through frango_var().
+func WithPathSuperglobals(enabled bool) Option {
+	return func(m *Middleware) {
+		m.pathSuperglobals = enabled
+	}
+}
+
+// writeUtilityScript writes the helper script and registers it as auto_prepend_file.
+// A user-configured auto_prepend_file is chained so it still runs after the helpers.
+func (m *Middleware) writeUtilityScript() error {
+	utilityPath := filepath.Join(m.tempDir, utilityFileName)
+
+	script := utilityScript
+	userPrepend := strings.Trim(m.phpIni["auto_prepend_file"], `"'`)
+	if userPrepend != utilityPath {
+		m.userPrepend = userPrepend
+	}
+	if m.userPrepend != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(m.userPrepend)
+		script += fmt.Sprintf("\nrequire_once '%s';\n", escaped)
+	}
+
+	if err := os.WriteFile(utilityPath, []byte(script), 0644); err != nil {
+		return fmt.Errorf("error writing utility script: %w", err)
+	}
+
+	m.phpIni["auto_prepend_file"] = `"` + utilityPath + `"`
+	return nil
+}
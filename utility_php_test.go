@@ -0,0 +1,36 @@
+//go:build !nofrankenphp
+
+package frango
+
+import (
+	"net/http"
+	"testing"
+)
+
+// renderKeysScript prints the render keys frango passed, sorted, and one decoded value
+const renderKeysScript = `<?php
+$keys = frango_render_keys();
+sort($keys);
+echo implode(',', $keys), '|', $_RENDER['user']['name'] ?? '-';
+`
+
+func TestRenderKeysInPHP(t *testing.T) {
+	m, cleanup := NewTestInstance(map[string]string{"keys.php": renderKeysScript}, quietLogger())
+	defer cleanup()
+
+	m.HandleRender("/render-keys", "keys.php", func(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+		return map[string]interface{}{
+			"title": "Dashboard",
+			"user":  map[string]string{"name": "Ada"},
+			"tags":  []string{"a", "b"},
+		}
+	})
+	m.HandlePHP("/plain-keys", "keys.php")
+
+	if recorder := serve(m, http.MethodGet, "/render-keys"); recorder.Body.String() != "tags,title,user|Ada" {
+		t.Errorf("render route printed %q, want the three render keys", recorder.Body.String())
+	}
+	if recorder := serve(m, http.MethodGet, "/plain-keys"); recorder.Body.String() != "|-" {
+		t.Errorf("plain route printed %q, want no render keys", recorder.Body.String())
+	}
+}
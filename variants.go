@@ -0,0 +1,154 @@
+package frango
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// scriptVariant is an alternate representation of a script and the selector that picks it
+type scriptVariant struct {
+	// queryKey/queryValue select the variant through a query parameter (?amp=1)
+	queryKey   string
+	queryValue string
+	// header/headerValue select the variant through a request header (Accept-Variant: amp)
+	header      string
+	headerValue string
+	// scriptPath is the absolute path of the variant script
+	scriptPath string
+}
+
+// variantConfig holds WithVariants arguments until the source directory is resolved
+type variantConfig struct {
+	scriptPath string
+	variants   map[string]string
+}
+
+// WithVariants maps alternate representations of a script to other scripts.
+// Selectors are either a query parameter ("?amp=1", or "?amp" to match any value)
+// or a request header ("X-Variant: amp"). Paths are relative to the source directory.
+// Responses carry Link rel="alternate" headers pointing at the other query-selectable
+// representations, and header selectors are added to Vary.
+func WithVariants(scriptPath string, variants map[string]string) Option {
+	return func(m *Middleware) {
+		m.variantConfigs = append(m.variantConfigs, variantConfig{scriptPath, variants})
+	}
+}
+
+// resolveVariants parses the configured variant selectors against the source directory
+func (m *Middleware) resolveVariants() error {
+	for _, config := range m.variantConfigs {
+		basePath := m.absScriptPath(config.scriptPath)
+
+		// Sort selectors so matching order is deterministic
+		selectors := make([]string, 0, len(config.variants))
+		for selector := range config.variants {
+			selectors = append(selectors, selector)
+		}
+		sort.Strings(selectors)
+
+		for _, selector := range selectors {
+			variant, err := parseVariantSelector(selector)
+			if err != nil {
+				return err
+			}
+			variant.scriptPath = m.absScriptPath(config.variants[selector])
+			m.variants[basePath] = append(m.variants[basePath], variant)
+		}
+	}
+	return nil
+}
+
+// absScriptPath resolves a script path against the source directory
+func (m *Middleware) absScriptPath(scriptPath string) string {
+	if !filepath.IsAbs(scriptPath) {
+		scriptPath = filepath.Join(m.sourceDir, scriptPath)
+	}
+	return filepath.Clean(scriptPath)
+}
+
+// parseVariantSelector parses "?key=value" and "Header: value" selectors
+func parseVariantSelector(selector string) (scriptVariant, error) {
+	if strings.HasPrefix(selector, "?") {
+		key, value, _ := strings.Cut(strings.TrimPrefix(selector, "?"), "=")
+		if key == "" {
+			return scriptVariant{}, fmt.Errorf("invalid variant selector %q: missing query parameter", selector)
+		}
+		return scriptVariant{queryKey: key, queryValue: value}, nil
+	}
+
+	if header, value, found := strings.Cut(selector, ":"); found && strings.TrimSpace(header) != "" {
+		return scriptVariant{
+			header:      textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(header)),
+			headerValue: strings.TrimSpace(value),
+		}, nil
+	}
+
+	return scriptVariant{}, fmt.Errorf("invalid variant selector %q: expected \"?param=value\" or \"Header: value\"", selector)
+}
+
+// matches reports whether the request selects this variant
+func (v scriptVariant) matches(r *http.Request) bool {
+	if v.queryKey != "" {
+		values, found := r.URL.Query()[v.queryKey]
+		if !found {
+			return false
+		}
+		return v.queryValue == "" || (len(values) > 0 && values[0] == v.queryValue)
+	}
+	return strings.EqualFold(r.Header.Get(v.header), v.headerValue)
+}
+
+// selectVariant picks the representation to serve for a script and sets the
+// Link and Vary headers describing the alternatives
+func (m *Middleware) selectVariant(w http.ResponseWriter, r *http.Request, sourcePath string) string {
+	variants := m.variants[filepath.Clean(sourcePath)]
+	if len(variants) == 0 {
+		return sourcePath
+	}
+
+	var selected *scriptVariant
+	for i := range variants {
+		if variants[i].matches(r) {
+			selected = &variants[i]
+			break
+		}
+	}
+
+	for i := range variants {
+		variant := &variants[i]
+		if variant.header != "" {
+			w.Header().Add("Vary", variant.header)
+			continue
+		}
+		if variant == selected {
+			continue
+		}
+
+		// Advertise the other query-selectable representations
+		alternate := *r.URL
+		query := alternate.Query()
+		query.Set(variant.queryKey, variant.queryValue)
+		alternate.RawQuery = query.Encode()
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"alternate\"", alternate.RequestURI()))
+	}
+
+	if selected == nil {
+		return sourcePath
+	}
+
+	// Point back at the default representation
+	if selected.queryKey != "" {
+		canonical := *r.URL
+		query := canonical.Query()
+		query.Del(selected.queryKey)
+		canonical.RawQuery = query.Encode()
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"alternate\"", canonical.RequestURI()))
+	}
+
+	m.logger.Printf("Serving variant %s for %s", selected.scriptPath, sourcePath)
+	return selected.scriptPath
+}
@@ -0,0 +1,111 @@
+package frango
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// VersionGroup serves routes under a shared prefix and middleware chain, such as one
+// version of an API (see APIVersion) or a route group (see Group)
+type VersionGroup struct {
+	m          *Middleware
+	prefix     string
+	dir        string
+	middleware []func(http.Handler) http.Handler
+	mux        *http.ServeMux
+	handler    http.Handler
+}
+
+// VersionOption configures a VersionGroup
+type VersionOption func(g *VersionGroup)
+
+// WithVersionDir sets the directory holding the version's scripts, relative to the
+// source directory. Defaults to the prefix, so /v1 resolves scripts under v1/.
+func WithVersionDir(dir string) VersionOption {
+	return func(g *VersionGroup) {
+		g.dir = dir
+	}
+}
+
+// WithVersionMiddleware wraps every route of the version with the given middleware,
+// applied in order (the first one runs outermost)
+func WithVersionMiddleware(middleware ...func(http.Handler) http.Handler) VersionOption {
+	return func(g *VersionGroup) {
+		g.middleware = append(g.middleware, middleware...)
+	}
+}
+
+// APIVersion creates a route group mounted under prefix (e.g. "/v1") so that /v1/users
+// and /v2/users can run different PHP implementations. Register routes with Handle and
+// mount the group on a router, e.g. mux.Handle("/v1/", php.APIVersion("/v1")).
+func (m *Middleware) APIVersion(prefix string, opts ...VersionOption) *VersionGroup {
+	g := m.newVersionGroup(prefix, opts...)
+	m.logger.Printf("Created API version %s serving scripts from %s", g.prefix, g.dir)
+	return g
+}
+
+// Group creates a route group mounted under prefix (e.g. "/admin") whose routes all run
+// through the given middleware before PHP, applied in order (the first one runs
+// outermost). Unlike APIVersion, script paths are relative to the source directory.
+// Mount it on a router, e.g. mux.Handle("/admin/", php.Group("/admin", requireAuth)).
+// A "/" prefix groups routes at the root.
+func (m *Middleware) Group(prefix string, middleware ...func(http.Handler) http.Handler) *VersionGroup {
+	g := m.newVersionGroup(prefix, WithVersionDir(""), WithVersionMiddleware(middleware...))
+	m.logger.Printf("Created route group %s with %d middleware", g.Prefix(), len(middleware))
+	return g
+}
+
+// newVersionGroup creates a route group under prefix. The root prefix "/" is stored
+// empty so routes don't start with "//".
+func (m *Middleware) newVersionGroup(prefix string, opts ...VersionOption) *VersionGroup {
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix = "/" + prefix
+	}
+
+	g := &VersionGroup{
+		m:      m,
+		prefix: prefix,
+		dir:    strings.TrimPrefix(prefix, "/"),
+		mux:    http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	// Apply middleware in reverse so the first one runs outermost
+	g.handler = g.mux
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		g.handler = g.middleware[i](g.handler)
+	}
+	return g
+}
+
+// Handle registers a route under the version prefix. The pattern uses net/http syntax
+// without the prefix (e.g. "GET /users/{id}") and scriptPath is relative to the version directory.
+func (g *VersionGroup) Handle(pattern string, scriptPath string) {
+	method, path := "", pattern
+	if parts := strings.SplitN(pattern, " ", 2); len(parts) == 2 {
+		method, path = parts[0]+" ", strings.TrimSpace(parts[1])
+	}
+	fullPattern := method + g.prefix + "/" + strings.TrimPrefix(path, "/")
+	versionScript := filepath.Join(g.dir, scriptPath)
+
+	g.mux.HandleFunc(fullPattern, func(w http.ResponseWriter, r *http.Request) {
+		g.m.serveScript(versionScript, w, r)
+	})
+	g.m.logger.Printf("Registered %s to %s", fullPattern, versionScript)
+}
+
+// Prefix returns the URL prefix the version is served under, "/" for the root
+func (g *VersionGroup) Prefix() string {
+	if g.prefix == "" {
+		return "/"
+	}
+	return g.prefix
+}
+
+// ServeHTTP serves a request through the version's middleware and routes
+func (g *VersionGroup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.handler.ServeHTTP(w, r)
+}
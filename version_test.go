@@ -0,0 +1,51 @@
+package frango
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupLogsAsGroup(t *testing.T) {
+	var logs bytes.Buffer
+	m := &Middleware{logger: log.New(&logs, "", 0)}
+
+	m.Group("/admin")
+	if strings.Contains(logs.String(), "API version") || !strings.Contains(logs.String(), "Created route group /admin") {
+		t.Errorf("Group logged:\n%s", logs.String())
+	}
+
+	logs.Reset()
+	m.APIVersion("/v1")
+	if !strings.Contains(logs.String(), "Created API version /v1") {
+		t.Errorf("APIVersion logged:\n%s", logs.String())
+	}
+}
+
+func TestRootGroupPatterns(t *testing.T) {
+	var logs bytes.Buffer
+	m := &Middleware{logger: log.New(&logs, "", 0)}
+
+	for _, prefix := range []string{"/", ""} {
+		g := m.Group(prefix)
+		if g.Prefix() != "/" {
+			t.Errorf("Group(%q).Prefix() = %q, want /", prefix, g.Prefix())
+		}
+		g.Handle("GET /users", "users.php")
+		if _, pattern := g.mux.Handler(httptest.NewRequest(http.MethodGet, "/users", nil)); pattern != "GET /users" {
+			t.Errorf("Group(%q) registered %q, want GET /users", prefix, pattern)
+		}
+	}
+	if strings.Contains(logs.String(), "//") {
+		t.Errorf("root group built a // pattern:\n%s", logs.String())
+	}
+
+	g := m.Group("/admin/")
+	g.Handle("/users", "admin/users.php")
+	if _, pattern := g.mux.Handler(httptest.NewRequest(http.MethodGet, "/admin/users", nil)); pattern != "/admin/users" {
+		t.Errorf("Group(/admin/) registered %q, want /admin/users", pattern)
+	}
+}
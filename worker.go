@@ -0,0 +1,65 @@
+package frango
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// WithWorkerMode boots the given scripts as persistent FrankenPHP workers when PHP is
+// initialized. Relative paths are resolved against the source directory. Worker
+// scripts run in place from the source directory (not from a mirrored environment)
+// and must use frankenphp_handle_request() to process requests. Scripts without a
+// worker keep the regular one-shot execution.
+func WithWorkerMode(scriptPaths []string, numWorkers int) Option {
+	return func(m *Middleware) {
+		m.workerPaths = append(m.workerPaths, scriptPaths...)
+		m.numWorkers = numWorkers
+	}
+}
+
+// WithNumThreads sets how many PHP threads FrankenPHP starts (0 for its default of
+// twice GOMAXPROCS). Worker pools run on these threads, so with WithWorkerMode the
+// count must exceed the total number of workers, leaving threads for other scripts.
+func WithNumThreads(numThreads int) Option {
+	return func(m *Middleware) {
+		m.numThreads = numThreads
+	}
+}
+
+// resolveWorkerScripts turns the configured worker paths into absolute script paths
+// and checks the thread count leaves room for them
+func (m *Middleware) resolveWorkerScripts() error {
+	if m.numWorkers < 0 {
+		return fmt.Errorf("invalid worker count: %d", m.numWorkers)
+	}
+	if m.numThreads < 0 {
+		return fmt.Errorf("invalid thread count: %d", m.numThreads)
+	}
+
+	for _, scriptPath := range m.workerPaths {
+		m.workerScripts[m.absScriptPath(scriptPath)] = true
+	}
+
+	if m.numThreads > 0 && len(m.workerScripts) > 0 {
+		// FrankenPHP sizes pools without an explicit count at twice GOMAXPROCS
+		perScript := m.numWorkers
+		if perScript == 0 {
+			perScript = runtime.GOMAXPROCS(0) * 2
+		}
+		if total := perScript * len(m.workerScripts); m.numThreads <= total {
+			return fmt.Errorf("thread count %d must exceed the %d worker threads", m.numThreads, total)
+		}
+	}
+	return nil
+}
+
+// workerPools maps each worker script to its pool size
+func (m *Middleware) workerPools() map[string]int {
+	pools := make(map[string]int, len(m.workerScripts))
+	for scriptPath := range m.workerScripts {
+		// A count of 0 lets FrankenPHP pick its default pool size
+		pools[scriptPath] = m.numWorkers
+		m.logger.Printf("Starting %d PHP workers for %s", m.numWorkers, scriptPath)
+	}
+	return pools
+}